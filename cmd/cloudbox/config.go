@@ -8,12 +8,20 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
+	"github.com/Luzifer/cloudbox/providers"
 	"github.com/Luzifer/cloudbox/sync"
 )
 
 type shareConfig struct {
-	OverrideURI bool   `yaml:"override_uri"`
-	URITemplate string `yaml:"uri_template"`
+	DefaultExpiry time.Duration       `yaml:"default_expiry"`
+	Mode          providers.ShareMode `yaml:"mode"`
+	OverrideURI   bool                `yaml:"override_uri"`
+	PublicExpiry  time.Duration       `yaml:"public_expiry"`
+	URITemplate   string              `yaml:"uri_template"`
+}
+
+type trashConfig struct {
+	Retention time.Duration `yaml:"retention"`
 }
 
 type syncConfig struct {
@@ -26,6 +34,7 @@ type configFile struct {
 	ControlDir string      `yaml:"control_dir"`
 	Sync       syncConfig  `yaml:"sync"`
 	Share      shareConfig `yaml:"share"`
+	Trash      trashConfig `yaml:"trash"`
 }
 
 func (c configFile) validate() error {
@@ -41,17 +50,48 @@ func (c configFile) validate() error {
 		return errors.New("Share URI override enabled but no template specified")
 	}
 
+	switch c.Share.Mode {
+	case providers.ShareModePublic:
+	case providers.ShareModePresigned:
+		if c.Share.DefaultExpiry <= 0 {
+			return errors.New("Presigned share mode enabled but no default expiry specified")
+		}
+	default:
+		return errors.Errorf("Unknown share mode %q", c.Share.Mode)
+	}
+
+	if c.Trash.Retention < 0 {
+		return errors.New("Trash retention must not be negative")
+	}
+
+	if err := c.Sync.Settings.Validate(); err != nil {
+		return errors.Wrap(err, "Invalid sync settings")
+	}
+
 	return nil
 }
 
 func defaultConfig() *configFile {
 	return &configFile{
 		ControlDir: "~/.cache/cloudbox",
+		Share: shareConfig{
+			DefaultExpiry: 24 * time.Hour,
+			Mode:          providers.ShareModePublic,
+		},
 		Sync: syncConfig{
 			Settings: sync.Config{
-				ScanInterval: time.Minute,
+				Concurrency:            4,
+				ConflictStrategy:       sync.ConflictManual,
+				DeleteConflictStrategy: sync.DeleteConflictUpdateWins,
+				MaxDeleteCount:         100,
+				MaxDeletePercent:       50,
+				ScanInterval:           time.Minute,
+				WatchDebounce:          2 * time.Second,
 			},
 		},
+		Trash: trashConfig{
+			Retention: 30 * 24 * time.Hour,
+		},
 	}
 }
 
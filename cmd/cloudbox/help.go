@@ -8,9 +8,15 @@ import (
 
 const helpText = `
 Available commands:
+  check-ignore    Explains which ignore rule matches the given path
   help            Display this message
+  plan            Prints the actions the next sync would execute
   share           Shares a file and returns its URL when supported
+  shares list     Lists all registered shares
   sync            Executes the bi-directional sync
+  trash list      Lists deleted and overwritten local files kept in the trash
+  trash restore   Restores the newest version of a file from the trash
+  unshare         Revokes all shares of a file
   write-config    Write a sample configuration to specified location
 `
 
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/rconfig"
+)
+
+func execCheckIgnore() error {
+	conf, err := loadConfig(false)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load config")
+	}
+
+	if len(rconfig.Args()) < 3 {
+		return errors.New("No path provided to check")
+	}
+
+	s, err := syncFromConfig(conf, false)
+	if err != nil {
+		return err
+	}
+
+	relativeName := rconfig.Args()[2]
+	if filepath.IsAbs(relativeName) {
+		// Allow checking absolute paths inside the sync directory
+		localDir, err := filepath.Abs(conf.Sync.LocalDir)
+		if err != nil {
+			return errors.Wrap(err, "Unable to calculate absolute path")
+		}
+
+		if !strings.HasPrefix(relativeName, localDir+"/") {
+			return errors.Errorf("Path %q is outside the sync directory", relativeName)
+		}
+		relativeName = strings.TrimPrefix(relativeName, localDir+"/")
+	}
+
+	rule, err := s.CheckIgnore(relativeName)
+	if err != nil {
+		return errors.Wrap(err, "Unable to check ignore rules")
+	}
+
+	switch {
+	case rule == nil:
+		fmt.Printf("%s: not ignored (no rule matched)\n", relativeName)
+	case rule.Negate:
+		fmt.Printf("%s:%d:%s\t%s: not ignored\n", rule.Source, rule.Line, rule.Pattern, relativeName)
+	default:
+		fmt.Printf("%s:%d:%s\t%s: ignored\n", rule.Source, rule.Line, rule.Pattern, relativeName)
+	}
+
+	return nil
+}
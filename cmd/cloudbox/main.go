@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
@@ -14,24 +15,38 @@ type command string
 type commandFunc func() error
 
 const (
+	cmdCheckIgnore command = "check-ignore"
 	cmdHelp        command = "help"
+	cmdPlan        command = "plan"
 	cmdShare       command = "share"
+	cmdShares      command = "shares"
 	cmdSync        command = "sync"
+	cmdTrash       command = "trash"
+	cmdUnshare     command = "unshare"
 	cmdWriteConfig command = "write-config"
 )
 
 var cmdFuncs = map[command]commandFunc{
+	cmdCheckIgnore: execCheckIgnore,
+	cmdPlan:        execPlan,
 	cmdShare:       execShare,
+	cmdShares:      execShares,
 	cmdSync:        execSync,
+	cmdTrash:       execTrash,
+	cmdUnshare:     execUnshare,
 	cmdWriteConfig: execWriteSampleConfig,
 }
 
 var (
 	cfg = struct {
-		Config         string `flag:"config,c" default:"config.yaml" description:"Configuration file location"`
-		Force          bool   `flag:"force,f" default:"false" description:"Force operation"`
-		LogLevel       string `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
-		VersionAndExit bool   `flag:"version" default:"false" description:"Prints current version and exits"`
+		Config         string        `flag:"config,c" default:"config.yaml" description:"Configuration file location"`
+		DryRun         bool          `flag:"dry-run,n" default:"false" description:"Only print planned sync actions, do not execute them"`
+		Expires        time.Duration `flag:"expires" default:"0s" description:"Share file using a presigned link expiring after this duration"`
+		Force          bool          `flag:"force,f" default:"false" description:"Force operation (i.e. sync despite triggered deletion safeguards, overwrite on restore)"`
+		Format         string        `flag:"format" default:"text" description:"Output format for plan (text, json)"`
+		LogLevel       string        `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
+		Once           bool          `flag:"once" default:"false" description:"Execute a single sync pass and exit"`
+		VersionAndExit bool          `flag:"version" default:"false" description:"Prints current version and exits"`
 	}{}
 
 	version = "dev"
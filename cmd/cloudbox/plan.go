@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+func execPlan() error {
+	conf, err := loadConfig(false)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load config")
+	}
+
+	s, err := syncFromConfig(conf, true)
+	if err != nil {
+		return err
+	}
+
+	plan, err := s.Plan()
+	if err != nil {
+		return errors.Wrap(err, "Unable to plan sync")
+	}
+
+	switch cfg.Format {
+	case "json":
+		return errors.Wrap(json.NewEncoder(os.Stdout).Encode(plan), "Unable to encode plan")
+
+	case "text":
+		if len(plan) == 0 {
+			fmt.Println("Everything in sync, nothing to do")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTION\tFILE\tCHANGE\tSTRATEGY")
+		for _, pa := range plan {
+			file := pa.RelativeName
+			if pa.MovedFrom != "" {
+				file = pa.MovedFrom + " -> " + file
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pa.Action, file, pa.Change, pa.Strategy)
+		}
+		return errors.Wrap(w.Flush(), "Unable to write plan")
+
+	default:
+		return errors.Errorf("Unknown output format %q", cfg.Format)
+	}
+}
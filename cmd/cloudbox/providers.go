@@ -4,13 +4,21 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/azure"
+	"github.com/Luzifer/cloudbox/providers/gcs"
 	"github.com/Luzifer/cloudbox/providers/local"
 	"github.com/Luzifer/cloudbox/providers/s3"
+	"github.com/Luzifer/cloudbox/providers/sftp"
+	"github.com/Luzifer/cloudbox/providers/webdav"
 )
 
 var providerInitFuncs = []providers.CloudProviderInitFunc{
 	local.New,
+	azure.New,
+	gcs.New,
 	s3.New,
+	sftp.New,
+	webdav.New,
 }
 
 func providerFromURI(uri string) (providers.CloudProvider, error) {
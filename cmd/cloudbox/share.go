@@ -1,13 +1,18 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/user"
+	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/sync"
 	"github.com/Luzifer/rconfig"
 )
 
@@ -17,10 +22,11 @@ func execShare() error {
 		return errors.Wrap(err, "Unable to load config")
 	}
 
-	remote, err := providerFromURI(conf.Sync.RemoteURI)
+	s, err := syncFromConfig(conf, false)
 	if err != nil {
-		return errors.Wrap(err, "Unable to initialize remote provider")
+		return err
 	}
+	remote := s.Remote()
 
 	if !remote.Capabilities().Has(providers.CapShare) {
 		return errors.New("Remote provider does not support sharing")
@@ -31,11 +37,53 @@ func execShare() error {
 	}
 
 	relativeName := rconfig.Args()[2]
-	providerURL, err := remote.Share(relativeName)
+
+	opts := providers.ShareOptions{Mode: conf.Share.Mode, Expires: conf.Share.DefaultExpiry}
+	if cfg.Expires > 0 {
+		opts = providers.ShareOptions{Mode: providers.ShareModePresigned, Expires: cfg.Expires}
+	}
+
+	if mc, ok := remote.(providers.ShareModeChecker); ok && !mc.SupportsShareMode(opts.Mode) {
+		return errors.Errorf("Remote provider does not support share mode %q, configure another share mode or pass --expires", opts.Mode)
+	}
+
+	file, err := remote.GetFile(relativeName)
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file")
+	}
+
+	providerURL, err := remote.Share(relativeName, opts)
 	if err != nil {
 		return errors.Wrap(err, "Unable to share file")
 	}
 
+	var expiresAt *time.Time
+	switch {
+	case opts.Mode == providers.ShareModePresigned:
+		t := time.Now().Add(opts.Expires).Truncate(time.Second)
+		if er, ok := remote.(providers.ShareExpiryReporter); ok {
+			// Expiry of the link differs from the requested one
+			t = er.ShareExpiry(t)
+		}
+		expiresAt = &t
+
+	case conf.Share.PublicExpiry > 0:
+		// Public shares are revoked by the sync after expiry
+		t := time.Now().Add(conf.Share.PublicExpiry).Truncate(time.Second)
+		expiresAt = &t
+	}
+
+	if err = s.RegisterShare(sync.Share{
+		RelativeName: relativeName,
+		URL:          providerURL,
+		Mode:         opts.Mode,
+		Creator:      shareCreator(),
+		CreatedAt:    time.Now(),
+		ExpiresAt:    expiresAt,
+	}); err != nil {
+		return errors.Wrap(err, "Unable to register share")
+	}
+
 	if !conf.Share.OverrideURI {
 		fmt.Println(providerURL)
 		return nil
@@ -47,10 +95,90 @@ func execShare() error {
 	}
 
 	if err := tpl.Execute(os.Stdout, map[string]interface{}{
-		"file": relativeName,
+		"expires": expiresAt,
+		"file":    relativeName,
+		"size":    file.Info().Size,
+		"url":     providerURL,
 	}); err != nil {
 		return errors.Wrap(err, "Unable to render share URI")
 	}
 
 	return nil
 }
+
+func execShares() error {
+	conf, err := loadConfig(false)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load config")
+	}
+
+	if len(rconfig.Args()) > 2 && rconfig.Args()[2] != "list" {
+		return errors.Errorf("Unknown shares command %q", rconfig.Args()[2])
+	}
+
+	s, err := syncFromConfig(conf, false)
+	if err != nil {
+		return err
+	}
+
+	shares, err := s.ListShares()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list shares")
+	}
+
+	switch cfg.Format {
+	case "json":
+		return errors.Wrap(json.NewEncoder(os.Stdout).Encode(shares), "Unable to encode shares")
+
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tMODE\tCREATOR\tEXPIRES\tURL")
+		for _, share := range shares {
+			expires := "never"
+			if share.ExpiresAt != nil {
+				expires = share.ExpiresAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", share.RelativeName, share.Mode, share.Creator, expires, share.URL)
+		}
+		return errors.Wrap(w.Flush(), "Unable to write shares")
+
+	default:
+		return errors.Errorf("Unknown output format %q", cfg.Format)
+	}
+}
+
+func execUnshare() error {
+	conf, err := loadConfig(false)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load config")
+	}
+
+	if len(rconfig.Args()) < 3 {
+		return errors.New("No filename provided to unshare")
+	}
+
+	s, err := syncFromConfig(conf, false)
+	if err != nil {
+		return err
+	}
+
+	if !s.Remote().Capabilities().Has(providers.CapShare) {
+		return errors.New("Remote provider does not support sharing")
+	}
+
+	return errors.Wrap(s.Unshare(rconfig.Args()[2]), "Unable to unshare file")
+}
+
+func shareCreator() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s", username, hostname)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"os"
 	"os/signal"
 	"path"
@@ -15,41 +17,96 @@ import (
 )
 
 func execSync() error {
+	if cfg.DryRun {
+		return execPlan()
+	}
+
 	conf, err := loadConfig(false)
 	if err != nil {
 		return errors.Wrap(err, "Unable to load config")
 	}
 
-	local, err := providerFromURI("file://" + conf.Sync.LocalDir)
+	s, err := syncFromConfig(conf, false)
 	if err != nil {
-		return errors.Wrap(err, "Unable to initialize local provider")
+		return err
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	if cfg.Once {
+		return execSyncOnce(s, sigchan)
+	}
+
+	go func() {
+		for range sigchan {
+			s.Stop()
+		}
+	}()
+
+	log.Info("Starting sync run...")
+	return errors.Wrap(s.Run(), "Unable to sync")
+}
+
+func execSyncOnce(s *sync.Sync, sigchan chan os.Signal) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		for range sigchan {
+			cancel()
+		}
+	}()
+
+	log.Info("Starting single sync run...")
+	summary, err := s.RunOnce(ctx)
+
+	fmt.Printf("Uploaded: %d, Downloaded: %d, Deleted: %d, Moved: %d, Conflicted: %d, Failed: %d\n",
+		summary.Uploaded, summary.Downloaded, summary.Deleted, summary.Moved, summary.Conflicted, summary.Failed)
+
+	return errors.Wrap(err, "Unable to sync")
+}
+
+// syncFromConfig creates the sync using the database in the control
+// directory. A read-only sync must not create or modify the database.
+func syncFromConfig(conf *configFile, readOnly bool) (*sync.Sync, error) {
+	local, _, err := localFromConfig(conf)
+	if err != nil {
+		return nil, err
 	}
 
 	remote, err := providerFromURI(conf.Sync.RemoteURI)
 	if err != nil {
-		return errors.Wrap(err, "Unable to initialize remote provider")
+		return nil, errors.Wrap(err, "Unable to initialize remote provider")
 	}
 
-	if err := os.MkdirAll(conf.ControlDir, 0700); err != nil {
-		return errors.Wrap(err, "Unable to create control dir")
+	dsn, err := databaseDSN(conf, readOnly)
+	if err != nil {
+		return nil, err
 	}
 
-	db, err := sql.Open("sqlite3", path.Join(conf.ControlDir, "sync.db"))
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
-		return errors.Wrap(err, "Unable to establish database connection")
+		return nil, errors.Wrap(err, "Unable to establish database connection")
 	}
 
-	s := sync.New(local, remote, db, conf.Sync.Settings, log.NewEntry(log.StandardLogger()))
+	settings := conf.Sync.Settings
+	settings.Force = cfg.Force
 
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	return sync.New(local, remote, db, settings, log.NewEntry(log.StandardLogger())), nil
+}
 
-	go func() {
-		for range sigchan {
-			s.Stop()
-		}
-	}()
+func databaseDSN(conf *configFile, readOnly bool) (string, error) {
+	dbPath := path.Join(conf.ControlDir, "sync.db")
 
-	log.Info("Starting sync run...")
-	return errors.Wrap(s.Run(), "Unable to sync")
+	if !readOnly {
+		return dbPath, errors.Wrap(os.MkdirAll(conf.ControlDir, 0700), "Unable to create control dir")
+	}
+
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		// Never synced: An empty database behaves the same
+		return ":memory:", nil
+	}
+
+	return "file:" + dbPath + "?mode=ro", nil
 }
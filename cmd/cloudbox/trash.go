@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/cloudbox/providers/local"
+	"github.com/Luzifer/rconfig"
+)
+
+func execTrash() error {
+	conf, err := loadConfig(false)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load config")
+	}
+
+	if conf.Trash.Retention == 0 {
+		return errors.New("Trash is disabled in config")
+	}
+
+	lp, trash, err := localFromConfig(conf)
+	if err != nil {
+		return err
+	}
+
+	subCmd := "list"
+	if len(rconfig.Args()) > 2 {
+		subCmd = rconfig.Args()[2]
+	}
+
+	switch subCmd {
+	case "list":
+		return listTrash(trash)
+
+	case "restore":
+		if len(rconfig.Args()) < 4 {
+			return errors.New("No filename provided to restore")
+		}
+
+		entry, err := lp.Restore(rconfig.Args()[3], cfg.Force)
+		if err != nil {
+			return errors.Wrap(err, "Unable to restore file")
+		}
+
+		log.WithFields(log.Fields{
+			"filename":   entry.RelativeName,
+			"trashed_at": entry.TrashedAt.Local().Format(time.RFC3339),
+		}).Info("File restored")
+		return nil
+
+	default:
+		return errors.Errorf("Unknown trash command %q", subCmd)
+	}
+}
+
+func listTrash(trash *local.Trash) error {
+	entries, err := trash.Entries()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list trash")
+	}
+
+	switch cfg.Format {
+	case "json":
+		return errors.Wrap(json.NewEncoder(os.Stdout).Encode(entries), "Unable to encode trash entries")
+
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tTRASHED\tSIZE")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", entry.RelativeName, entry.TrashedAt.Local().Format(time.RFC3339), entry.Size)
+		}
+		return errors.Wrap(w.Flush(), "Unable to write trash entries")
+
+	default:
+		return errors.Errorf("Unknown output format %q", cfg.Format)
+	}
+}
+
+// localFromConfig creates the provider for the local directory using
+// the trash if enabled. Expired trash entries are purged by the sync
+// loop, not here: Listing the trash must not modify it.
+func localFromConfig(conf *configFile) (*local.Provider, *local.Trash, error) {
+	p, err := local.New("file://" + conf.Sync.LocalDir)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to initialize local provider")
+	}
+	lp := p.(*local.Provider)
+
+	if conf.Trash.Retention == 0 {
+		return lp, nil, nil
+	}
+
+	trash := local.NewTrash(path.Join(conf.ControlDir, "trash"), conf.Trash.Retention)
+	lp.SetTrash(trash)
+
+	return lp, trash, nil
+}
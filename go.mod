@@ -3,13 +3,21 @@ module github.com/Luzifer/cloudbox
 go 1.12
 
 require (
+	cloud.google.com/go/storage v1.0.0
+	github.com/Azure/azure-storage-blob-go v0.8.0
 	github.com/Luzifer/rconfig v2.2.0+incompatible
 	github.com/aws/aws-sdk-go v1.20.12
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/pkg/errors v0.8.1
+	github.com/pkg/sftp v1.10.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/pflag v1.0.3 // indirect
+	golang.org/x/crypto v0.0.0-20190820162420-60c769a6c586
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
+	google.golang.org/api v0.9.0
 	gopkg.in/validator.v2 v2.0.0-20180514200540-135c24b11c19 // indirect
 	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,226 @@
+// Package ignore implements gitignore compatible pattern matching for
+// the relative file names handled by the sync engine
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileName is the name of the files containing ignore patterns
+const FileName = ".cloudboxignore"
+
+// SourceConfig is used as Rule.Source for patterns from the config
+const SourceConfig = "config"
+
+type Rule struct {
+	Source  string
+	Line    int
+	Pattern string
+	Negate  bool
+
+	base     string
+	depth    int
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+func parseRule(base, source string, line int, pattern string) (*Rule, bool) {
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(pattern, " ") && !strings.HasSuffix(pattern, `\ `) {
+		pattern = strings.TrimSuffix(pattern, " ")
+	}
+
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil, false
+	}
+
+	r := &Rule{
+		Source:  source,
+		Line:    line,
+		Pattern: pattern,
+		base:    base,
+		depth:   -1,
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		r.Negate = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasPrefix(pattern, `\#`) || strings.HasPrefix(pattern, `\!`) {
+		pattern = pattern[1:]
+	}
+	pattern = strings.Replace(pattern, `\ `, " ", -1)
+
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	// Patterns containing a slash are relative to the ignore file location
+	r.anchored = strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	if pattern == "" {
+		return nil, false
+	}
+
+	r.segments = strings.Split(pattern, "/")
+	return r, true
+}
+
+func (r Rule) matches(candidate string) bool {
+	if r.base != "" {
+		if !strings.HasPrefix(candidate, r.base+"/") {
+			return false
+		}
+		candidate = strings.TrimPrefix(candidate, r.base+"/")
+	}
+
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(candidate))
+		return ok
+	}
+
+	return matchSegments(r.segments, strings.Split(candidate, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		// A trailing "**" matches everything inside but not the directory itself
+		start := 0
+		if len(pattern) == 1 {
+			start = 1
+		}
+
+		for i := start; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+type Matcher struct {
+	rules []*Rule
+}
+
+// New creates a matcher containing the given global patterns
+func New(patterns []string) *Matcher {
+	m := &Matcher{}
+	for i, p := range patterns {
+		if r, ok := parseRule("", SourceConfig, i+1, p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m
+}
+
+// AddFile reads patterns from an ignore file located in the directory
+// base (relative to the sync root)
+func (m *Matcher) AddFile(base string, r io.Reader) error {
+	var (
+		line    int
+		scanner = bufio.NewScanner(r)
+		source  = path.Join(base, FileName)
+	)
+
+	base = strings.Trim(base, "/")
+	if base == "." {
+		base = ""
+	}
+
+	for scanner.Scan() {
+		line++
+		if rule, ok := parseRule(base, source, line, scanner.Text()); ok {
+			rule.depth = strings.Count(source, "/")
+			m.rules = append(m.rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "Unable to read ignore file")
+	}
+
+	// Rules from deeper ignore files take precedence over higher ones
+	sort.SliceStable(m.rules, func(i, j int) bool { return m.rules[i].depth < m.rules[j].depth })
+
+	return nil
+}
+
+// Match returns the rule deciding about the relative name or nil if
+// no rule matches. A returned rule having Negate set means the file
+// is explicitly not ignored. Like git files below an ignored directory
+// can't be re-included.
+func (m *Matcher) Match(relativeName string) *Rule {
+	if m == nil {
+		return nil
+	}
+
+	var (
+		parts = strings.Split(strings.Trim(relativeName, "/"), "/")
+		match *Rule
+	)
+
+	for i := 1; i <= len(parts); i++ {
+		isDir := i < len(parts)
+
+		r := m.lastMatch(strings.Join(parts[:i], "/"), isDir)
+		if r == nil {
+			continue
+		}
+
+		match = r
+		if isDir && !r.Negate {
+			break
+		}
+	}
+
+	return match
+}
+
+// lastMatch returns the last rule matching the candidate
+func (m *Matcher) lastMatch(candidate string, isDir bool) *Rule {
+	var match *Rule
+
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			// Files themselves never match directory patterns
+			continue
+		}
+
+		if r.matches(candidate) {
+			match = r
+		}
+	}
+
+	return match
+}
+
+// Ignored reports whether the relative name is excluded from sync
+func (m *Matcher) Ignored(relativeName string) bool {
+	r := m.Match(relativeName)
+	return r != nil && !r.Negate
+}
@@ -0,0 +1,135 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		files    map[string]string
+		ignored  []string
+		synced   []string
+	}{
+		{
+			name:     "unanchored",
+			patterns: []string{"*.log", "tmp"},
+			ignored:  []string{"a.log", "dir/b.log", "tmp", "dir/tmp/file.txt"},
+			synced:   []string{"a.txt", "log", "tmpfile"},
+		},
+		{
+			name:     "anchored",
+			patterns: []string{"/root.txt", "docs/*.md"},
+			ignored:  []string{"root.txt", "docs/readme.md"},
+			synced:   []string{"dir/root.txt", "other/docs/readme.md", "docs/sub/readme.md"},
+		},
+		{
+			name:     "dir only",
+			patterns: []string{"build/"},
+			ignored:  []string{"build/out.bin", "dir/build/out.bin"},
+			synced:   []string{"build", "dir/build"},
+		},
+		{
+			name:     "leading double star",
+			patterns: []string{"**/cache"},
+			ignored:  []string{"cache", "a/cache", "a/b/cache/file"},
+			synced:   []string{"cached", "a/cached"},
+		},
+		{
+			name:     "inner double star",
+			patterns: []string{"a/**/b"},
+			ignored:  []string{"a/b", "a/x/b", "a/x/y/b/file"},
+			synced:   []string{"b", "x/a/b", "a/bb"},
+		},
+		{
+			name:     "trailing double star",
+			patterns: []string{"foo/**"},
+			ignored:  []string{"foo/file", "foo/a/b/file"},
+			synced:   []string{"foo", "bar/foo/file"},
+		},
+		{
+			name:     "negation",
+			patterns: []string{"*.log", "!keep.log"},
+			ignored:  []string{"a.log", "dir/a.log"},
+			synced:   []string{"keep.log", "dir/keep.log"},
+		},
+		{
+			name:     "negation inside included directory",
+			patterns: []string{"build/*", "!build/keep.txt"},
+			ignored:  []string{"build/out.bin"},
+			synced:   []string{"build/keep.txt"},
+		},
+		{
+			name:     "no re-include below excluded directory",
+			patterns: []string{"build/", "!build/keep.txt"},
+			ignored:  []string{"build/out.bin", "build/keep.txt"},
+		},
+		{
+			name:     "escapes",
+			patterns: []string{`\#hash`, `\!bang`, `space\ `, "trailing   ", "# comment"},
+			ignored:  []string{"#hash", "!bang", "space ", "trailing"},
+			synced:   []string{"hash", "bang", "space", "# comment"},
+		},
+		{
+			name:     "nested ignore file",
+			patterns: []string{"*.txt"},
+			files:    map[string]string{"sub": "!*.txt\nlocal.bin\n/anchored.bin"},
+			ignored:  []string{"a.txt", "other/a.txt", "sub/local.bin", "sub/dir/local.bin", "sub/anchored.bin"},
+			synced:   []string{"sub/a.txt", "sub/dir/a.txt", "local.bin", "anchored.bin", "sub/dir/anchored.bin"},
+		},
+		{
+			name:     "nested ignore file takes precedence",
+			patterns: []string{"!*.txt"},
+			files:    map[string]string{"": "*.txt", "sub": "!*.txt", "sub/deep": "*.txt"},
+			ignored:  []string{"a.txt", "sub/deep/a.txt"},
+			synced:   []string{"sub/a.txt"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New(tc.patterns)
+			for base, content := range tc.files {
+				if err := m.AddFile(base, strings.NewReader(content)); err != nil {
+					t.Fatalf("Unable to add ignore file: %s", err)
+				}
+			}
+
+			for _, name := range tc.ignored {
+				if !m.Ignored(name) {
+					t.Errorf("Expected %q to be ignored", name)
+				}
+			}
+
+			for _, name := range tc.synced {
+				if m.Ignored(name) {
+					t.Errorf("Expected %q not to be ignored, matched %+v", name, m.Match(name))
+				}
+			}
+		})
+	}
+}
+
+func TestMatchReportsRule(t *testing.T) {
+	m := New(nil)
+	if err := m.AddFile("sub", strings.NewReader("# comment\n*.log\n")); err != nil {
+		t.Fatalf("Unable to add ignore file: %s", err)
+	}
+
+	r := m.Match("sub/a.log")
+	if r == nil {
+		t.Fatal("Expected a matching rule")
+	}
+
+	if r.Source != "sub/"+FileName || r.Line != 2 || r.Pattern != "*.log" {
+		t.Errorf("Unexpected rule %+v", r)
+	}
+
+	if m.Match("a.log") != nil {
+		t.Error("Expected rule not to apply outside its directory")
+	}
+
+	if (*Matcher)(nil).Match("a.log") != nil {
+		t.Error("Expected nil matcher not to match")
+	}
+}
@@ -0,0 +1,98 @@
+package azure
+
+import (
+	"crypto/md5" // #nosec G501 - MD5 is used as content checksum, not for security
+	"encoding/json"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+)
+
+const checksumCacheStatePrefix = "md5:"
+
+// checksumCacheEntry keeps the checksum calculated for a blob without
+// Content-MD5 (i.e. uploaded by other tools), every write to the blob
+// changes its ETag and invalidates the entry
+type checksumCacheEntry struct {
+	ETag     string `json:"etag"`
+	Checksum string `json:"checksum"`
+}
+
+// fillChecksum calculates the checksum of blobs without Content-MD5 as
+// CapAutoChecksum promises every file info carries one. The result is
+// cached to only download the blob once.
+func (p *Provider) fillChecksum(f *File, etag azblob.ETag) error {
+	if f.checksum != "" {
+		return nil
+	}
+
+	if cached, err := p.getChecksumCache(f.name, etag); err != nil || cached != "" {
+		f.checksum = cached
+		return err
+	}
+
+	cs, err := f.Checksum(md5.New()) // #nosec G401 - MD5 is used as content checksum, not for security
+	if err != nil {
+		return errors.Wrap(err, "Unable to calculate checksum")
+	}
+	f.checksum = cs
+
+	return p.setChecksumCache(f.name, etag, cs)
+}
+
+func (p *Provider) getChecksumCache(blobName string, etag azblob.ETag) (string, error) {
+	if p.state == nil {
+		return "", nil
+	}
+
+	raw, err := p.state.GetState(checksumCacheStatePrefix + blobName)
+	if err != nil || raw == "" {
+		return "", errors.Wrap(err, "Unable to read checksum cache")
+	}
+
+	entry := checksumCacheEntry{}
+	if err = json.Unmarshal([]byte(raw), &entry); err != nil || entry.ETag != string(etag) {
+		// Broken or outdated entries are replaced by a fresh calculation
+		return "", nil
+	}
+
+	return entry.Checksum, nil
+}
+
+func (p *Provider) setChecksumCache(blobName string, etag azblob.ETag, checksum string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(checksumCacheEntry{ETag: string(etag), Checksum: checksum})
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode checksum cache")
+	}
+
+	return errors.Wrap(p.state.SetState(checksumCacheStatePrefix+blobName, string(raw)), "Unable to store checksum cache")
+}
+
+// pruneChecksumCache removes cached checksums of blobs no longer listed
+func (p *Provider) pruneChecksumCache(listed map[string]bool) error {
+	if p.state == nil {
+		return nil
+	}
+
+	keys, err := p.state.ListStateKeys()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list checksum cache")
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, checksumCacheStatePrefix) || listed[strings.TrimPrefix(key, checksumCacheStatePrefix)] {
+			continue
+		}
+
+		if err := p.state.DeleteState(key); err != nil {
+			return errors.Wrap(err, "Unable to delete checksum cache")
+		}
+	}
+
+	return nil
+}
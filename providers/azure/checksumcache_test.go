@@ -0,0 +1,132 @@
+package azure
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// memoryStateStore is a StateStore keeping the state in memory
+type memoryStateStore struct {
+	lock  sync.Mutex
+	state map[string]string
+}
+
+func (m *memoryStateStore) DeleteState(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.state, key)
+	return nil
+}
+
+func (m *memoryStateStore) GetState(key string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.state[key], nil
+}
+
+func (m *memoryStateStore) ListStateKeys() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var keys []string
+	for key := range m.state {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *memoryStateStore) SetState(key, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.state[key] = value
+	return nil
+}
+
+// blobWithoutMD5 serves a container holding a single blob without
+// Content-MD5 as created by tools not setting it
+type blobWithoutMD5 struct {
+	lock      sync.Mutex
+	content   string
+	etag      string
+	downloads int
+}
+
+func (b *blobWithoutMD5) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	w.Header().Set("ETag", b.etag)
+	w.Header().Set("Last-Modified", "Mon, 01 Jul 2019 12:30:45 GMT")
+
+	if r.URL.Query().Get("comp") == "list" {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults ContainerName="test"><Blobs><Blob><Name>prefix/file.txt</Name><Properties>
+<Last-Modified>Mon, 01 Jul 2019 12:30:45 GMT</Last-Modified><Etag>%s</Etag>
+<Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType>
+</Properties><Metadata /></Blob></Blobs><NextMarker /></EnumerationResults>`, b.etag, len(b.content))
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b.content)))
+	w.Header().Set("X-Ms-Blob-Type", "BlockBlob")
+	if r.Method == http.MethodGet {
+		b.downloads++
+		fmt.Fprint(w, b.content)
+	}
+}
+
+func (b *blobWithoutMD5) update(content, etag string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.content, b.etag = content, etag
+}
+
+func TestListFilesCachesMissingChecksums(t *testing.T) {
+	blob := &blobWithoutMD5{content: "content", etag: `"0x1"`}
+	server := httptest.NewServer(blob)
+	defer server.Close()
+
+	p := newTestProvider(t, server.URL+"/"+devStoreAccount, "prefix")
+	if err := p.SetStateStore(&memoryStateStore{state: map[string]string{}}); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	listChecksum := func() string {
+		files, err := p.ListFiles()
+		if err != nil {
+			t.Fatalf("Unable to list files: %s", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("Expected one file, got %d", len(files))
+		}
+		return files[0].Info().Checksum
+	}
+
+	for i := 0; i < 2; i++ {
+		if cs, expected := listChecksum(), fmt.Sprintf("%x", md5.Sum([]byte("content"))); cs != expected {
+			t.Fatalf("Expected checksum %q, got %q", expected, cs)
+		}
+	}
+
+	if blob.downloads != 1 {
+		t.Errorf("Expected blob to be downloaded once, got %d downloads", blob.downloads)
+	}
+
+	blob.update("changed", `"0x2"`)
+	if cs, expected := listChecksum(), fmt.Sprintf("%x", md5.Sum([]byte("changed"))); cs != expected {
+		t.Errorf("Expected checksum of changed blob %q, got %q", expected, cs)
+	}
+
+	if blob.downloads != 2 {
+		t.Errorf("Expected changed blob to be downloaded again, got %d downloads", blob.downloads)
+	}
+}
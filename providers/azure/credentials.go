@@ -0,0 +1,113 @@
+package azure
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Well-known credentials of the storage emulators (Azurite)
+	devStoreAccount  = "devstoreaccount1"
+	devStoreKey      = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	devStoreEndpoint = "http://127.0.0.1:10000/devstoreaccount1"
+)
+
+// credentials contains the account information required to access
+// the blob service with a shared key
+type credentials struct {
+	account  string
+	key      string
+	endpoint string
+}
+
+// credentialsFromURI collects credentials from (in order) the URI,
+// a connection string or the environment
+func credentialsFromURI(u *url.URL) (*credentials, error) {
+	cred := &credentials{
+		account:  u.Host,
+		endpoint: u.Query().Get("endpoint"),
+	}
+
+	connStr := u.Query().Get("connection_string")
+	if connStr == "" {
+		connStr = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	}
+
+	if pass, ok := u.User.Password(); ok {
+		cred.key = pass
+	} else if connStr != "" {
+		if err := cred.parseConnectionString(connStr); err != nil {
+			return nil, errors.Wrap(err, "Invalid connection string")
+		}
+	} else {
+		cred.key = os.Getenv("AZURE_STORAGE_KEY")
+	}
+
+	if cred.key == "" {
+		return nil, errors.New("No account key found")
+	}
+
+	if cred.endpoint == "" {
+		cred.endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cred.account)
+	}
+	cred.endpoint = strings.TrimRight(cred.endpoint, "/")
+
+	return cred, nil
+}
+
+func (c *credentials) parseConnectionString(connStr string) error {
+	var (
+		fields   = map[string]string{}
+		protocol = "https"
+		suffix   = "core.windows.net"
+	)
+
+	for _, part := range strings.Split(connStr, ";") {
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("Invalid part %q", part)
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	if fields["UseDevelopmentStorage"] == "true" {
+		c.account, c.key = devStoreAccount, devStoreKey
+		if c.endpoint == "" {
+			c.endpoint = devStoreEndpoint
+		}
+		return nil
+	}
+
+	if fields["AccountName"] != "" && fields["AccountName"] != c.account {
+		return errors.Errorf("Connection string is for account %q", fields["AccountName"])
+	}
+
+	if fields["AccountKey"] == "" {
+		return errors.New("AccountKey is required")
+	}
+	c.key = fields["AccountKey"]
+
+	if v := fields["DefaultEndpointsProtocol"]; v != "" {
+		protocol = v
+	}
+	if v := fields["EndpointSuffix"]; v != "" {
+		suffix = v
+	}
+
+	if c.endpoint == "" {
+		c.endpoint = fields["BlobEndpoint"]
+	}
+	if c.endpoint == "" {
+		c.endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, c.account, suffix)
+	}
+
+	return nil
+}
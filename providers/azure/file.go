@@ -0,0 +1,56 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type File struct {
+	name         string
+	lastModified time.Time
+	checksum     string
+	size         uint64
+
+	provider *Provider
+}
+
+func (f File) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: strings.TrimPrefix(f.name, f.provider.blobPrefix()),
+		LastModified: f.lastModified,
+		Checksum:     f.checksum,
+		Size:         f.size,
+	}
+}
+
+func (f File) Checksum(h hash.Hash) (string, error) {
+	cont, err := f.Content()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to get file content")
+	}
+	defer cont.Close()
+
+	if _, err := io.Copy(h, cont); err != nil {
+		return "", errors.Wrap(err, "Unable to read file content")
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f File) Content() (io.ReadCloser, error) {
+	resp, err := f.provider.container.NewBlobURL(f.name).Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to get file")
+	}
+
+	return resp.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
@@ -0,0 +1,319 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 - MD5 is used as content checksum, not for security
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	defaultBlockSize   = 16 * 1024 * 1024
+	defaultConcurrency = 4
+	maxBlocks          = 50000
+	// metaMtime stores the modification time of the source file as the
+	// blob LastModified is the time of the upload. Metadata names must
+	// be valid C# identifiers.
+	metaMtime = "cloudbox_mtime"
+)
+
+type Provider struct {
+	blockSize   int64
+	concurrency int
+	container   azblob.ContainerURL
+	credential  *azblob.SharedKeyCredential
+	prefix      string
+	state       providers.StateStore
+}
+
+func New(uri string) (providers.CloudProvider, error) {
+	if !strings.HasPrefix(uri, "azblob://") {
+		return nil, providers.ErrInvalidURI
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid URI specified")
+	}
+
+	// Path contains the container and the optional prefix
+	pathParts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if pathParts[0] == "" {
+		return nil, errors.New("No container specified")
+	}
+
+	cred, err := credentialsFromURI(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to load credentials")
+	}
+
+	p := &Provider{
+		blockSize:   defaultBlockSize,
+		concurrency: defaultConcurrency,
+	}
+
+	if len(pathParts) == 2 {
+		p.prefix = strings.Trim(pathParts[1], "/")
+	}
+
+	if p.credential, err = azblob.NewSharedKeyCredential(cred.account, cred.key); err != nil {
+		return nil, errors.Wrap(err, "Invalid account key")
+	}
+
+	containerURL, err := url.Parse(cred.endpoint + "/" + pathParts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid endpoint specified")
+	}
+	p.container = azblob.NewContainerURL(*containerURL, azblob.NewPipeline(p.credential, azblob.PipelineOptions{}))
+
+	if v := u.Query().Get("block_size"); v != "" {
+		if p.blockSize, err = strconv.ParseInt(v, 10, 64); err != nil || p.blockSize < 1 || p.blockSize > azblob.BlockBlobMaxStageBlockBytes {
+			return nil, errors.Errorf("Invalid block_size, needs to be between 1 and %d bytes", int64(azblob.BlockBlobMaxStageBlockBytes))
+		}
+	}
+
+	if v := u.Query().Get("concurrency"); v != "" {
+		if p.concurrency, err = strconv.Atoi(v); err != nil || p.concurrency < 1 {
+			return nil, errors.New("Invalid concurrency, needs to be a positive integer")
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Provider) Capabilities() providers.Capability {
+	return providers.CapBasic | providers.CapAutoChecksum | providers.CapShare
+}
+func (p *Provider) Name() string                 { return "azure" }
+func (p *Provider) GetChecksumMethod() hash.Hash { return md5.New() }
+
+func (p *Provider) DeleteFile(relativeName string) error {
+	_, err := p.blob(relativeName).Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	if isServiceCode(err, azblob.ServiceCodeBlobNotFound) {
+		return providers.ErrFileNotFound
+	}
+
+	return errors.Wrap(err, "Unable to delete blob")
+}
+
+func (p *Provider) GetFile(relativeName string) (providers.File, error) {
+	props, err := p.blob(relativeName).GetProperties(context.Background(), azblob.BlobAccessConditions{})
+	if err != nil {
+		if isServiceCode(err, azblob.ServiceCodeBlobNotFound) || isStatus(err, http.StatusNotFound) {
+			// HEAD responses don't carry a service code in their body
+			return nil, providers.ErrFileNotFound
+		}
+		return nil, errors.Wrap(err, "Unable to fetch blob properties")
+	}
+
+	f := File{
+		name:         p.relativeNameToBlobName(relativeName),
+		lastModified: lastModifiedFor(props.LastModified(), props.NewMetadata()),
+		checksum:     fmt.Sprintf("%x", props.ContentMD5()),
+		size:         uint64(props.ContentLength()),
+
+		provider: p,
+	}
+
+	if err := p.fillChecksum(&f, props.ETag()); err != nil {
+		return nil, errors.Wrap(err, "Unable to get checksum of blob")
+	}
+
+	return f, nil
+}
+
+func (p *Provider) ListFiles() ([]providers.File, error) {
+	var (
+		ctx    = context.Background()
+		files  []providers.File
+		listed = map[string]bool{}
+	)
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := p.container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Details: azblob.BlobListingDetails{Metadata: true},
+			Prefix:  p.blobPrefix(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to list blobs")
+		}
+		marker = resp.NextMarker
+
+		// In contrast to S3 listings contain all blob properties
+		for _, item := range resp.Segment.BlobItems {
+			var size uint64
+			if item.Properties.ContentLength != nil {
+				size = uint64(*item.Properties.ContentLength)
+			}
+
+			f := File{
+				name:         item.Name,
+				lastModified: lastModifiedFor(item.Properties.LastModified, item.Metadata),
+				checksum:     fmt.Sprintf("%x", item.Properties.ContentMD5),
+				size:         size,
+
+				provider: p,
+			}
+
+			if err := p.fillChecksum(&f, item.Properties.Etag); err != nil {
+				return nil, errors.Wrapf(err, "Unable to get checksum of blob %q", item.Name)
+			}
+
+			listed[item.Name] = true
+			files = append(files, f)
+		}
+	}
+
+	return files, errors.Wrap(p.pruneChecksumCache(listed), "Unable to prune checksum cache")
+}
+
+func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	if int64(f.Info().Size) >= p.blockSize {
+		if err := p.putBlocks(f); err != nil {
+			return nil, errors.Wrap(err, "Unable to write file")
+		}
+
+		return p.GetFile(f.Info().RelativeName)
+	}
+
+	body, err := f.Content()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to get file reader")
+	}
+	defer body.Close()
+
+	// Files smaller than one block are buffered in memory
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, body); err != nil {
+		return nil, errors.Wrap(err, "Unable to read source file")
+	}
+
+	contentMD5 := md5.Sum(buf.Bytes()) // #nosec G401 - MD5 is used as content checksum, not for security
+
+	if _, err = p.blob(f.Info().RelativeName).ToBlockBlobURL().Upload(
+		context.Background(),
+		bytes.NewReader(buf.Bytes()),
+		azblob.BlobHTTPHeaders{ContentMD5: contentMD5[:]},
+		blobMetadata(f.Info()),
+		azblob.BlobAccessConditions{},
+	); err != nil {
+		return nil, errors.Wrap(err, "Unable to write file")
+	}
+
+	return p.GetFile(f.Info().RelativeName)
+}
+
+// Share creates a read-only SAS link. Blobs can't be published on their
+// own as public access is a property of the whole container.
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	if opts.Mode != providers.ShareModePresigned {
+		return "", errors.Wrapf(providers.ErrFeatureNotSupported,
+			"Azure does not support public shares of single blobs, use share mode %q", providers.ShareModePresigned)
+	}
+
+	if opts.Expires <= 0 {
+		return "", errors.New("Expiry must be greater than 0")
+	}
+
+	blobURL := p.blob(relativeName).URL()
+
+	protocol := azblob.SASProtocolHTTPS
+	if blobURL.Scheme == "http" {
+		// Storage emulators don't support HTTPS
+		protocol = azblob.SASProtocolHTTPSandHTTP
+	}
+
+	sas, err := azblob.BlobSASSignatureValues{
+		BlobName:      p.relativeNameToBlobName(relativeName),
+		ContainerName: p.containerName(),
+		ExpiryTime:    time.Now().UTC().Add(opts.Expires),
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+		Protocol:      protocol,
+	}.NewSASQueryParameters(p.credential)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to sign SAS token")
+	}
+
+	blobURL.RawQuery = sas.Encode()
+	return blobURL.String(), nil
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	return nil, providers.ErrFeatureNotSupported
+}
+
+// SupportsShareMode reports only presigned shares to be supported as
+// public access is a property of the whole container
+func (p *Provider) SupportsShareMode(mode providers.ShareMode) bool {
+	return mode == providers.ShareModePresigned
+}
+
+// Unshare has nothing to do as blobs are never published: SAS links are
+// not stored server-side and stay valid until they expire
+func (p *Provider) Unshare(relativeName string) error {
+	return nil
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) blob(relativeName string) azblob.BlobURL {
+	return p.container.NewBlobURL(p.relativeNameToBlobName(relativeName))
+}
+
+func (p *Provider) containerName() string {
+	u := p.container.URL()
+	return path.Base(u.Path)
+}
+
+func (p *Provider) blobPrefix() string {
+	if p.prefix == "" {
+		return ""
+	}
+	return p.prefix + "/"
+}
+
+func (p *Provider) relativeNameToBlobName(relativeName string) string {
+	return p.blobPrefix() + relativeName
+}
+
+// lastModifiedFor prefers the source modification time stored by
+// cloudbox over the upload time of the blob
+func lastModifiedFor(blobLastModified time.Time, meta azblob.Metadata) time.Time {
+	if mtime, err := time.Parse(time.RFC3339Nano, meta[metaMtime]); err == nil {
+		return mtime
+	}
+	return blobLastModified
+}
+
+func blobMetadata(info providers.FileInfo) azblob.Metadata {
+	return azblob.Metadata{
+		metaMtime: info.LastModified.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Storage errors implement Cause() themselves so errors.Cause would
+// unwrap them: Errors must be checked before being wrapped.
+func isServiceCode(err error, code azblob.ServiceCodeType) bool {
+	stgErr, ok := err.(azblob.StorageError)
+	return ok && stgErr.ServiceCode() == code
+}
+
+func isStatus(err error, code int) bool {
+	stgErr, ok := err.(azblob.StorageError)
+	return ok && stgErr.Response() != nil && stgErr.Response().StatusCode == code
+}
@@ -0,0 +1,225 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+// newTestProvider creates a provider for the prefix in the container
+// "test" using the emulator credentials and the given endpoint
+func newTestProvider(t *testing.T, endpoint, prefix string) *Provider {
+	p, err := New((&url.URL{
+		Scheme:   "azblob",
+		User:     url.UserPassword("", devStoreKey),
+		Host:     devStoreAccount,
+		Path:     "/test/" + prefix,
+		RawQuery: url.Values{"endpoint": {endpoint}}.Encode(),
+	}).String())
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	return p.(*Provider)
+}
+
+// TestProvider runs the conformance suite against a storage emulator
+// (i.e. Azurite) with the well-known development account, its blob
+// endpoint is read from CLOUDBOX_TEST_AZURE_ENDPOINT
+func TestProvider(t *testing.T) {
+	endpoint := os.Getenv("CLOUDBOX_TEST_AZURE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CLOUDBOX_TEST_AZURE_ENDPOINT not set")
+	}
+
+	_, err := newTestProvider(t, endpoint, "").container.Create(context.Background(), azblob.Metadata{}, azblob.PublicAccessNone)
+	if err != nil && !isServiceCode(err, azblob.ServiceCodeContainerAlreadyExists) {
+		t.Fatalf("Unable to create container: %s", err)
+	}
+
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		// Every test gets its own prefix to start with an empty location
+		return newTestProvider(t, endpoint, fmt.Sprintf("cloudbox-%d", time.Now().UnixNano()))
+	})
+}
+
+func TestSharePublicIsExplained(t *testing.T) {
+	p := newTestProvider(t, devStoreEndpoint, "prefix")
+
+	_, err := p.Share("file.txt", providers.ShareOptions{Mode: providers.ShareModePublic})
+	if errors.Cause(err) != providers.ErrFeatureNotSupported {
+		t.Fatalf("Expected ErrFeatureNotSupported, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), string(providers.ShareModePresigned)) {
+		t.Errorf("Expected error to point to the presigned share mode, got %q", err)
+	}
+}
+
+func TestSupportsOnlyPresignedShares(t *testing.T) {
+	p := newTestProvider(t, devStoreEndpoint, "prefix")
+
+	if p.SupportsShareMode(providers.ShareModePublic) || !p.SupportsShareMode(providers.ShareModePresigned) {
+		t.Error("Expected only presigned shares to be supported")
+	}
+
+	if err := p.Unshare("file.txt"); err != nil {
+		t.Errorf("Expected unshare to have nothing to revoke, got %s", err)
+	}
+}
+
+func TestSharePresigned(t *testing.T) {
+	p := newTestProvider(t, devStoreEndpoint, "prefix")
+
+	shareURL, err := p.Share("file.txt", providers.ShareOptions{Mode: providers.ShareModePresigned, Expires: time.Hour})
+	if err != nil {
+		t.Fatalf("Unable to share file: %s", err)
+	}
+
+	u, err := url.Parse(shareURL)
+	if err != nil {
+		t.Fatalf("Invalid share URL %q: %s", shareURL, err)
+	}
+
+	if u.Path != "/"+devStoreAccount+"/test/prefix/file.txt" {
+		t.Errorf("Unexpected blob path %q", u.Path)
+	}
+
+	if u.Query().Get("sig") == "" || u.Query().Get("sp") != "r" {
+		t.Errorf("Expected a read-only signature, got %q", u.RawQuery)
+	}
+}
+
+// testFile is used to put content into the provider under test
+type testFile struct {
+	relativeName string
+	content      []byte
+}
+
+func (f testFile) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: time.Date(2019, 7, 1, 12, 30, 45, 0, time.UTC),
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f testFile) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f testFile) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+// blockRecorder accepts staged blocks and records the headers of the
+// committed block list
+type blockRecorder struct {
+	lock      sync.Mutex
+	delay     time.Duration
+	blocks    int
+	committed http.Header
+}
+
+func (b *blockRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(b.delay)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch r.URL.Query().Get("comp") {
+	case "block":
+		b.blocks++
+	case "blocklist":
+		b.committed = r.Header
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestPutBlocksSetsContentMD5(t *testing.T) {
+	recorder := &blockRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	p := newTestProvider(t, server.URL+"/"+devStoreAccount, "prefix")
+	p.blockSize = 4
+
+	content := []byte("content staged in blocks")
+	if err := p.putBlocks(testFile{relativeName: "file.txt", content: content}); err != nil {
+		t.Fatalf("Unable to put blocks: %s", err)
+	}
+
+	if expected := (len(content) + 3) / 4; recorder.blocks != expected {
+		t.Errorf("Expected %d staged blocks, got %d", expected, recorder.blocks)
+	}
+
+	if recorder.committed == nil {
+		t.Fatal("Block list was not committed")
+	}
+
+	sum := md5.Sum(content)
+	if expected, got := base64.StdEncoding.EncodeToString(sum[:]), recorder.committed.Get("X-Ms-Blob-Content-Md5"); got != expected {
+		t.Errorf("Expected Content-MD5 %q on commit, got %q", expected, got)
+	}
+}
+
+// truncatedFile reports a larger size than its content has
+type truncatedFile struct {
+	testFile
+	size uint64
+}
+
+func (f truncatedFile) Info() providers.FileInfo {
+	info := f.testFile.Info()
+	info.Size = f.size
+	return info
+}
+
+func TestPutBlocksWaitsForStagedBlocksOnReadError(t *testing.T) {
+	recorder := &blockRecorder{delay: 50 * time.Millisecond}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	p := newTestProvider(t, server.URL+"/"+devStoreAccount, "prefix")
+	p.blockSize = 4
+
+	err := p.putBlocks(truncatedFile{testFile: testFile{relativeName: "file.txt", content: []byte("twelve bytes")}, size: 24})
+	if err == nil {
+		t.Fatal("Expected truncated source to fail")
+	}
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	if recorder.blocks != 3 {
+		t.Errorf("Expected all 3 read blocks to be staged before returning, got %d", recorder.blocks)
+	}
+
+	if recorder.committed != nil {
+		t.Error("Expected block list not to be committed")
+	}
+}
@@ -0,0 +1,217 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 - MD5 is used as content checksum, not for security
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const blockStatePrefix = "blocks:"
+
+type blockState struct {
+	BlockSize    int64     `json:"block_size"`
+	Size         uint64    `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// SetStateStore enables resuming uploads across runs. Uncommitted
+// blocks are discarded by the service after a week so there is nothing
+// to clean up.
+func (p *Provider) SetStateStore(store providers.StateStore) error {
+	p.state = store
+	return nil
+}
+
+func (p *Provider) getBlockState(stateKey string) (*blockState, error) {
+	if p.state == nil {
+		return nil, nil
+	}
+
+	raw, err := p.state.GetState(stateKey)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	bs := &blockState{}
+	return bs, errors.Wrap(json.Unmarshal([]byte(raw), bs), "Unable to decode upload state")
+}
+
+func (p *Provider) setBlockState(stateKey string, bs blockState) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(bs)
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode upload state")
+	}
+
+	return p.state.SetState(stateKey, string(raw))
+}
+
+func (p *Provider) deleteBlockState(stateKey string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	return p.state.DeleteState(stateKey)
+}
+
+// blockID generates deterministic IDs to be able to identify blocks
+// staged in a previous run. All IDs of a blob must have the same length.
+func blockID(blockNo int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", blockNo)))
+}
+
+// stagedBlocks returns the blocks staged by a previous run of the same
+// upload which don't need to be staged again
+func (p *Provider) stagedBlocks(bbURL azblob.BlockBlobURL, info providers.FileInfo, blockSize int64) (map[string]int64, error) {
+	stateKey := blockStatePrefix + p.relativeNameToBlobName(info.RelativeName)
+
+	bs, err := p.getBlockState(stateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read upload state")
+	}
+
+	staged := map[string]int64{}
+
+	if bs != nil && bs.Size == info.Size && bs.LastModified.Equal(info.LastModified) && bs.BlockSize == blockSize {
+		list, err := bbURL.GetBlockList(context.Background(), azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+		if err == nil {
+			for _, b := range list.UncommittedBlocks {
+				staged[b.Name] = int64(b.Size)
+			}
+			return staged, nil
+		}
+		// Blob or blocks vanished: Start over
+	}
+
+	return staged, errors.Wrap(p.setBlockState(stateKey, blockState{
+		BlockSize:    blockSize,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+	}), "Unable to store upload state")
+}
+
+func (p *Provider) putBlocks(f providers.File) error {
+	var (
+		info      = f.Info()
+		bbURL     = p.blob(info.RelativeName).ToBlockBlobURL()
+		blockSize = p.blockSize
+	)
+
+	if int64(info.Size) > blockSize*maxBlocks {
+		// Blobs are limited in the number of blocks: Increase block size for huge files
+		blockSize = (int64(info.Size) + maxBlocks - 1) / maxBlocks
+	}
+
+	staged, err := p.stagedBlocks(bbURL, info, blockSize)
+	if err != nil {
+		return err
+	}
+
+	content, err := f.Content()
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file reader")
+	}
+	defer content.Close()
+
+	// The service does not calculate the Content-MD5 for blobs committed
+	// from blocks: Hash all content including blocks staged in previous
+	// runs and pass it when committing the block list
+	var (
+		contentMD5 = md5.New() // #nosec G401 - MD5 is used as content checksum, not for security
+		body       = io.TeeReader(content, contentMD5)
+
+		blockIDs           []string
+		readErr, uploadErr error
+
+		lock = new(sync.Mutex)
+		sem  = make(chan struct{}, p.concurrency)
+		wg   = new(sync.WaitGroup)
+	)
+
+	for blockNo, offset := int64(0), int64(0); offset < int64(info.Size); blockNo++ {
+		lock.Lock()
+		failed := uploadErr != nil
+		lock.Unlock()
+		if failed {
+			break
+		}
+
+		var (
+			id      = blockID(blockNo)
+			expSize = blockSize
+		)
+		if remain := int64(info.Size) - offset; remain < expSize {
+			expSize = remain
+		}
+		blockIDs = append(blockIDs, id)
+		offset += expSize
+
+		if staged[id] == expSize {
+			// Staged in a previous run: Skip its content
+			if _, err := io.CopyN(ioutil.Discard, body, expSize); err != nil {
+				readErr = errors.Wrap(err, "Unable to read source file")
+				break
+			}
+			continue
+		}
+
+		sem <- struct{}{}
+		buf := make([]byte, expSize)
+		if _, err := io.ReadFull(body, buf); err != nil {
+			<-sem
+			readErr = errors.Wrap(err, "Unable to read source file")
+			break
+		}
+
+		wg.Add(1)
+		go func(id string, data []byte) {
+			defer func() { <-sem; wg.Done() }()
+
+			_, err := bbURL.StageBlock(context.Background(), id, bytes.NewReader(data), azblob.LeaseAccessConditions{}, nil)
+			if err != nil {
+				lock.Lock()
+				if uploadErr == nil {
+					uploadErr = errors.Wrap(err, "Unable to stage block")
+				}
+				lock.Unlock()
+			}
+		}(id, buf)
+	}
+
+	// Blocks in flight must not outlive the upload
+	wg.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if uploadErr != nil {
+		// Upload state is kept to resume the upload in the next run
+		return uploadErr
+	}
+
+	if _, err = bbURL.CommitBlockList(
+		context.Background(),
+		blockIDs,
+		azblob.BlobHTTPHeaders{ContentMD5: contentMD5.Sum(nil)},
+		blobMetadata(info),
+		azblob.BlobAccessConditions{},
+	); err != nil {
+		return errors.Wrap(err, "Unable to commit block list")
+	}
+
+	return errors.Wrap(p.deleteBlockState(blockStatePrefix+p.relativeNameToBlobName(info.RelativeName)), "Unable to delete upload state")
+}
@@ -16,6 +16,27 @@ type File interface {
 	Content() (io.ReadCloser, error)
 }
 
+// VerifiableFile is a file knowing the hash method the checksum in its
+// info was created with, receiving providers use it to verify the
+// transferred content
+type VerifiableFile interface {
+	File
+	ChecksumMethod() hash.Hash
+}
+
+type verifiableFile struct {
+	File
+	method func() hash.Hash
+}
+
+func (v verifiableFile) ChecksumMethod() hash.Hash { return v.method() }
+
+// WithChecksumMethod attaches the checksum method of the provider the
+// file was retrieved from (its GetChecksumMethod) to the file
+func WithChecksumMethod(f File, method func() hash.Hash) VerifiableFile {
+	return verifiableFile{File: f, method: method}
+}
+
 type FileInfo struct {
 	RelativeName string
 	LastModified time.Time
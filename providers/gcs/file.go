@@ -0,0 +1,67 @@
+package gcs
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type File struct {
+	key          string
+	lastModified time.Time
+	checksum     string
+	size         uint64
+
+	provider *Provider
+}
+
+func (f File) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: strings.TrimPrefix(f.key, f.provider.keyPrefix()),
+		LastModified: f.lastModified,
+		Checksum:     f.checksum,
+		Size:         f.size,
+	}
+}
+
+func (f File) Checksum(h hash.Hash) (string, error) {
+	cont, err := f.Content()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to get file content")
+	}
+	defer cont.Close()
+
+	if _, err := io.Copy(h, cont); err != nil {
+		return "", errors.Wrap(err, "Unable to read file content")
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f File) Content() (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/download/storage/v1/b/%s/o/%s?alt=media",
+		f.provider.endpoint, url.PathEscape(f.provider.bucket), url.PathEscape(f.key)), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create request")
+	}
+
+	resp, err := f.provider.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to get file")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("Unexpected status %d on download", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
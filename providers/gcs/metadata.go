@@ -0,0 +1,38 @@
+package gcs
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// metaMtime stores the modification time of the source file as the
+// object update time is the time of the upload
+const metaMtime = "cloudbox-mtime"
+
+// checksumFor uses the MD5 of the content and falls back to the CRC32C
+// for composite objects which don't have an MD5
+func checksumFor(attrs *storage.ObjectAttrs) string {
+	if len(attrs.MD5) > 0 {
+		return fmt.Sprintf("%x", attrs.MD5)
+	}
+	return fmt.Sprintf("%08x", attrs.CRC32C)
+}
+
+// lastModifiedFor prefers the source modification time stored by
+// cloudbox over the upload time of the object
+func lastModifiedFor(attrs *storage.ObjectAttrs) time.Time {
+	if mtime, err := time.Parse(time.RFC3339Nano, attrs.Metadata[metaMtime]); err == nil {
+		return mtime
+	}
+	return attrs.Updated
+}
+
+func objectMetadata(info providers.FileInfo) map[string]string {
+	return map[string]string{
+		metaMtime: info.LastModified.UTC().Format(time.RFC3339Nano),
+	}
+}
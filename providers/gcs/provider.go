@@ -0,0 +1,332 @@
+package gcs
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 - MD5 is used as content checksum, not for security
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	defaultChunkSize = 16 * 1024 * 1024
+	// Chunks of resumable uploads must be a multiple of 256 KiB
+	chunkSizeMultiple = 256 * 1024
+	defaultEndpoint   = "https://storage.googleapis.com"
+	// maxSignedURLExpiry is the maximum lifetime of V4 signed URLs
+	maxSignedURLExpiry = 7 * 24 * time.Hour
+)
+
+type Provider struct {
+	bucket    string
+	chunkSize int64
+	endpoint  string
+	prefix    string
+
+	client     *storage.Client
+	httpClient *http.Client
+	signer     *jwt.Config
+	state      providers.StateStore
+}
+
+func New(uri string) (providers.CloudProvider, error) {
+	if !strings.HasPrefix(uri, "gs://") {
+		return nil, providers.ErrInvalidURI
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid URI specified")
+	}
+
+	p := &Provider{
+		bucket:    u.Host,
+		chunkSize: defaultChunkSize,
+		endpoint:  defaultEndpoint,
+		prefix:    strings.Trim(u.Path, "/"),
+	}
+
+	if v := u.Query().Get("chunk_size"); v != "" {
+		if p.chunkSize, err = strconv.ParseInt(v, 10, 64); err != nil || p.chunkSize < chunkSizeMultiple || p.chunkSize%chunkSizeMultiple != 0 {
+			return nil, errors.Errorf("Invalid chunk_size, needs to be a multiple of %d bytes", chunkSizeMultiple)
+		}
+	}
+
+	ctx := context.Background()
+
+	if ep := u.Query().Get("endpoint"); ep != "" {
+		// Emulators like fake-gcs-server don't require authentication
+		p.endpoint = strings.TrimRight(ep, "/")
+		p.httpClient = http.DefaultClient
+	} else {
+		var opts = []option.ClientOption{option.WithScopes(storage.ScopeFullControl)}
+
+		// Service account credentials are also required for signed URLs,
+		// without them application default credentials are used
+		credentialsFile := u.Query().Get("credentials")
+		if credentialsFile == "" {
+			credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+
+		if credentialsFile != "" {
+			opts = append(opts, option.WithCredentialsFile(credentialsFile))
+			if p.signer, err = loadSigner(credentialsFile); err != nil {
+				return nil, errors.Wrap(err, "Unable to load credentials")
+			}
+		}
+
+		if p.httpClient, _, err = htransport.NewClient(ctx, opts...); err != nil {
+			return nil, errors.Wrap(err, "Unable to create authenticated client")
+		}
+	}
+
+	var clientOpts = []option.ClientOption{option.WithHTTPClient(p.httpClient)}
+	if p.endpoint != defaultEndpoint {
+		clientOpts = append(clientOpts, option.WithEndpoint(p.endpoint+"/storage/v1/"))
+	}
+
+	if p.client, err = storage.NewClient(ctx, clientOpts...); err != nil {
+		return nil, errors.Wrap(err, "Unable to create storage client")
+	}
+
+	return p, nil
+}
+
+// loadSigner reads the service account key used to sign URLs, other
+// credential types can't sign and are silently skipped
+func loadSigner(credentialsFile string) (*jwt.Config, error) {
+	raw, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read credentials file")
+	}
+
+	var cred struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &cred); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode credentials file")
+	}
+
+	if cred.Type != "service_account" {
+		return nil, nil
+	}
+
+	conf, err := google.JWTConfigFromJSON(raw)
+	return conf, errors.Wrap(err, "Unable to parse service account key")
+}
+
+func (p *Provider) Capabilities() providers.Capability {
+	return providers.CapBasic | providers.CapAutoChecksum | providers.CapShare
+}
+func (p *Provider) Name() string                 { return "gcs" }
+func (p *Provider) GetChecksumMethod() hash.Hash { return md5.New() }
+
+func (p *Provider) DeleteFile(relativeName string) error {
+	err := p.object(relativeName).Delete(context.Background())
+	if err == storage.ErrObjectNotExist {
+		return providers.ErrFileNotFound
+	}
+
+	return errors.Wrap(err, "Unable to delete object")
+}
+
+func (p *Provider) GetFile(relativeName string) (providers.File, error) {
+	attrs, err := p.object(relativeName).Attrs(context.Background())
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, providers.ErrFileNotFound
+		}
+		return nil, errors.Wrap(err, "Unable to fetch object attributes")
+	}
+
+	return p.fileFromAttrs(attrs), nil
+}
+
+func (p *Provider) fileFromAttrs(attrs *storage.ObjectAttrs) File {
+	return File{
+		key:          attrs.Name,
+		lastModified: lastModifiedFor(attrs),
+		checksum:     checksumFor(attrs),
+		size:         uint64(attrs.Size),
+
+		provider: p,
+	}
+}
+
+func (p *Provider) ListFiles() ([]providers.File, error) {
+	var (
+		files []providers.File
+		it    = p.client.Bucket(p.bucket).Objects(context.Background(), &storage.Query{Prefix: p.keyPrefix()})
+	)
+
+	// In contrast to S3 listings contain all object attributes
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to list objects")
+		}
+
+		if strings.HasSuffix(attrs.Name, "/") {
+			// Placeholder objects created by the console to emulate directories
+			continue
+		}
+
+		files = append(files, p.fileFromAttrs(attrs))
+	}
+
+	return files, nil
+}
+
+func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	info := f.Info()
+
+	predefinedACL, err := p.predefinedACL(info.RelativeName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to determine object ACL")
+	}
+
+	if int64(info.Size) >= p.chunkSize {
+		if err := p.putResumable(f, predefinedACL); err != nil {
+			return nil, errors.Wrap(err, "Unable to write file")
+		}
+
+		return p.GetFile(info.RelativeName)
+	}
+
+	if err := p.putSimple(f, predefinedACL); err != nil {
+		return nil, errors.Wrap(err, "Unable to write file")
+	}
+
+	return p.GetFile(info.RelativeName)
+}
+
+// predefinedACL keeps public objects public when they are replaced,
+// otherwise the bucket default object ACL applies
+func (p *Provider) predefinedACL(relativeName string) (string, error) {
+	rules, err := p.object(relativeName).ACL().List(context.Background())
+	switch {
+	case err == storage.ErrObjectNotExist, isStatus(err, http.StatusNotFound):
+		// New objects get the bucket default object ACL
+		return "", nil
+	case isStatus(err, http.StatusBadRequest):
+		// Bucket uses uniform bucket-level access, ACLs are not available
+		return "", nil
+	case err != nil:
+		return "", errors.Wrap(err, "Unable to list object ACL")
+	}
+
+	for _, r := range rules {
+		if r.Entity == storage.AllUsers && r.Role == storage.RoleReader {
+			return "publicRead", nil
+		}
+	}
+
+	return "", nil
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	return nil, providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	switch opts.Mode {
+	case providers.ShareModePresigned:
+		return p.shareSigned(relativeName, opts.Expires)
+	case "", providers.ShareModePublic:
+		return p.sharePublic(relativeName)
+	default:
+		return "", providers.ErrFeatureNotSupported
+	}
+}
+
+func (p *Provider) shareSigned(relativeName string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > maxSignedURLExpiry {
+		return "", errors.Errorf("Expiry must be between 0 and %s", maxSignedURLExpiry)
+	}
+
+	if p.signer == nil {
+		return "", errors.New("Signed URLs require service account credentials")
+	}
+
+	shareURL, err := storage.SignedURL(p.bucket, p.relativeNameToKey(relativeName), &storage.SignedURLOptions{
+		GoogleAccessID: p.signer.Email,
+		PrivateKey:     p.signer.PrivateKey,
+		Method:         http.MethodGet,
+		Expires:        time.Now().Add(expires),
+		Scheme:         storage.SigningSchemeV4,
+	})
+	return shareURL, errors.Wrap(err, "Unable to sign URL")
+}
+
+func (p *Provider) sharePublic(relativeName string) (string, error) {
+	err := p.object(relativeName).ACL().Set(context.Background(), storage.AllUsers, storage.RoleReader)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to publish file")
+	}
+
+	return p.objectURL(p.relativeNameToKey(relativeName)), nil
+}
+
+func (p *Provider) Unshare(relativeName string) error {
+	if _, err := p.GetFile(relativeName); err != nil {
+		return err
+	}
+
+	err := p.object(relativeName).ACL().Delete(context.Background(), storage.AllUsers)
+	if isStatus(err, http.StatusNotFound) {
+		// File was not public
+		return nil
+	}
+
+	return errors.Wrap(err, "Unable to unpublish file")
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) object(relativeName string) *storage.ObjectHandle {
+	return p.client.Bucket(p.bucket).Object(p.relativeNameToKey(relativeName))
+}
+
+func (p *Provider) objectURL(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	return fmt.Sprintf("%s/%s/%s", p.endpoint, p.bucket, escapedKey)
+}
+
+func (p *Provider) keyPrefix() string {
+	if p.prefix == "" {
+		return ""
+	}
+	return p.prefix + "/"
+}
+
+func (p *Provider) relativeNameToKey(relativeName string) string {
+	return p.keyPrefix() + relativeName
+}
+
+func isStatus(err error, code int) bool {
+	apiErr, ok := errors.Cause(err).(*googleapi.Error)
+	return ok && apiErr.Code == code
+}
@@ -0,0 +1,52 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+const testBucket = "cloudbox-test"
+
+// newTestProvider creates a provider for the prefix in the test bucket
+// served by the emulator at the given endpoint
+func newTestProvider(t *testing.T, endpoint, prefix string) *Provider {
+	p, err := New((&url.URL{
+		Scheme:   "gs",
+		Host:     testBucket,
+		Path:     "/" + prefix,
+		RawQuery: url.Values{"endpoint": {endpoint}}.Encode(),
+	}).String())
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	return p.(*Provider)
+}
+
+// TestProvider runs the conformance suite against an emulator (i.e.
+// fake-gcs-server) at the endpoint read from CLOUDBOX_TEST_GCS_ENDPOINT
+func TestProvider(t *testing.T) {
+	endpoint := os.Getenv("CLOUDBOX_TEST_GCS_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CLOUDBOX_TEST_GCS_ENDPOINT not set")
+	}
+
+	p := newTestProvider(t, endpoint, "")
+	if _, err := p.client.Bucket(testBucket).Attrs(context.Background()); err != nil {
+		if err = p.client.Bucket(testBucket).Create(context.Background(), "cloudbox", nil); err != nil {
+			t.Fatalf("Unable to create bucket: %s", err)
+		}
+	}
+
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		// Every test gets its own prefix to start with an empty location
+		return newTestProvider(t, endpoint, fmt.Sprintf("cloudbox-%d", time.Now().UnixNano()))
+	})
+}
@@ -0,0 +1,342 @@
+package gcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const resumableStatePrefix = "resumable:"
+
+var errSessionExpired = errors.New("Upload session expired")
+
+type resumableState struct {
+	SessionURI   string    `json:"session_uri"`
+	Size         uint64    `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// SetStateStore enables resuming uploads across runs. In contrast to
+// S3 multipart uploads unfinished sessions don't occupy storage and
+// expire after a week so there is nothing to clean up.
+func (p *Provider) SetStateStore(store providers.StateStore) error {
+	p.state = store
+	return nil
+}
+
+func (p *Provider) getResumableState(stateKey string) (*resumableState, error) {
+	if p.state == nil {
+		return nil, nil
+	}
+
+	raw, err := p.state.GetState(stateKey)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	rs := &resumableState{}
+	return rs, errors.Wrap(json.Unmarshal([]byte(raw), rs), "Unable to decode upload state")
+}
+
+func (p *Provider) setResumableState(stateKey string, rs resumableState) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(rs)
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode upload state")
+	}
+
+	return p.state.SetState(stateKey, string(raw))
+}
+
+func (p *Provider) deleteResumableState(stateKey string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	return p.state.DeleteState(stateKey)
+}
+
+func (p *Provider) uploadURL(key, uploadType, predefinedACL string) string {
+	params := url.Values{
+		"name":       []string{key},
+		"uploadType": []string{uploadType},
+	}
+	if predefinedACL != "" {
+		params.Set("predefinedAcl", predefinedACL)
+	}
+
+	return fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", p.endpoint, url.PathEscape(p.bucket), params.Encode())
+}
+
+func objectResource(key string, info providers.FileInfo) ([]byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"name":     key,
+		"metadata": objectMetadata(info),
+	})
+	return body, errors.Wrap(err, "Unable to encode object metadata")
+}
+
+// putSimple uploads files smaller than one chunk in a single request
+// carrying both metadata and content
+func (p *Provider) putSimple(f providers.File, predefinedACL string) error {
+	var (
+		info = f.Info()
+		key  = p.relativeNameToKey(info.RelativeName)
+	)
+
+	resource, err := objectResource(key, info)
+	if err != nil {
+		return err
+	}
+
+	content, err := f.Content()
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file reader")
+	}
+	defer content.Close()
+
+	var (
+		body = new(bytes.Buffer)
+		mw   = multipart.NewWriter(body)
+	)
+
+	metaPart, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": []string{"application/json; charset=UTF-8"}})
+	if err != nil {
+		return errors.Wrap(err, "Unable to create metadata part")
+	}
+	if _, err = metaPart.Write(resource); err != nil {
+		return errors.Wrap(err, "Unable to write metadata part")
+	}
+
+	contentPart, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": []string{"application/octet-stream"}})
+	if err != nil {
+		return errors.Wrap(err, "Unable to create content part")
+	}
+	if _, err = io.Copy(contentPart, content); err != nil {
+		return errors.Wrap(err, "Unable to read source file")
+	}
+
+	if err = mw.Close(); err != nil {
+		return errors.Wrap(err, "Unable to finalize request body")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.uploadURL(key, "multipart", predefinedACL), body)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "Unable to upload file")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("Unexpected status %d on upload", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// resumeOrCreateSession continues a previously interrupted upload of
+// the same file or starts a new resumable upload session
+func (p *Provider) resumeOrCreateSession(key string, info providers.FileInfo, predefinedACL string) (string, int64, error) {
+	stateKey := resumableStatePrefix + key
+
+	rs, err := p.getResumableState(stateKey)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Unable to read upload state")
+	}
+
+	if rs != nil {
+		if rs.Size == info.Size && rs.LastModified.Equal(info.LastModified) {
+			if offset, err := p.uploadedBytes(rs.SessionURI, info.Size); err == nil {
+				return rs.SessionURI, offset, nil
+			}
+			// Session vanished (i.e. expired): Start over
+		}
+
+		if err := p.deleteResumableState(stateKey); err != nil {
+			return "", 0, errors.Wrap(err, "Unable to delete upload state")
+		}
+	}
+
+	sessionURI, err := p.createSession(key, info, predefinedACL)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := p.setResumableState(stateKey, resumableState{
+		SessionURI:   sessionURI,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+	}); err != nil {
+		return "", 0, errors.Wrap(err, "Unable to store upload state")
+	}
+
+	return sessionURI, 0, nil
+}
+
+func (p *Provider) createSession(key string, info providers.FileInfo, predefinedACL string) (string, error) {
+	body, err := objectResource(key, info)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.uploadURL(key, "resumable", predefinedACL), bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatUint(info.Size, 10))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to create upload session")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Location") == "" {
+		return "", errors.Errorf("Unexpected status %d on upload session creation", resp.StatusCode)
+	}
+
+	return resp.Header.Get("Location"), nil
+}
+
+// uploadedBytes queries the number of bytes already persisted within
+// the upload session
+func (p *Provider) uploadedBytes(sessionURI string, size uint64) (int64, error) {
+	req, err := http.NewRequest(http.MethodPut, sessionURI, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to query upload status")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		// Upload was completed but the resulting object must not be
+		// trusted blindly as it might have been finalized truncated
+		var obj struct {
+			Size string `json:"size"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil || obj.Size != strconv.FormatUint(size, 10) {
+			return 0, errSessionExpired
+		}
+		return int64(size), nil
+	case http.StatusPermanentRedirect:
+		return rangeEnd(resp.Header.Get("Range"))
+	case http.StatusNotFound, http.StatusGone:
+		return 0, errSessionExpired
+	default:
+		return 0, errors.Errorf("Unexpected status %d on upload status query", resp.StatusCode)
+	}
+}
+
+// rangeEnd parses the "bytes=0-<n>" range of persisted bytes into the
+// offset to continue at
+func rangeEnd(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+
+	idx := strings.LastIndex(header, "-")
+	if idx < 0 {
+		return 0, errors.Errorf("Invalid range header %q", header)
+	}
+
+	end, err := strconv.ParseInt(header[idx+1:], 10, 64)
+	return end + 1, errors.Wrapf(err, "Invalid range header %q", header)
+}
+
+func (p *Provider) putResumable(f providers.File, predefinedACL string) error {
+	var (
+		info = f.Info()
+		key  = p.relativeNameToKey(info.RelativeName)
+		size = int64(info.Size)
+	)
+
+	sessionURI, offset, err := p.resumeOrCreateSession(key, info, predefinedACL)
+	if err != nil {
+		return err
+	}
+
+	body, err := f.Content()
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file reader")
+	}
+	defer body.Close()
+
+	if offset > 0 {
+		// Uploaded in a previous run: Skip its content
+		if _, err := io.CopyN(ioutil.Discard, body, offset); err != nil {
+			return errors.Wrap(err, "Unable to read source file")
+		}
+	}
+
+	buf := make([]byte, p.chunkSize)
+	for offset < size {
+		n, err := io.ReadFull(body, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return errors.Wrap(err, "Unable to read source file")
+		}
+
+		req, err := http.NewRequest(http.MethodPut, sessionURI, bytes.NewReader(buf[:n]))
+		if err != nil {
+			return errors.Wrap(err, "Unable to create request")
+		}
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, size))
+
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			// Upload state is kept to resume the upload in the next run
+			return errors.Wrapf(err, "Unable to upload chunk at offset %d", offset)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusCreated:
+			offset = size
+
+		case http.StatusPermanentRedirect:
+			persisted, err := rangeEnd(resp.Header.Get("Range"))
+			if err != nil {
+				return err
+			}
+
+			if persisted != offset+int64(n) {
+				// Server did not persist the whole chunk, the next run
+				// continues at the offset reported by the server
+				return errors.Errorf("Chunk at offset %d was only partially persisted", offset)
+			}
+			offset = persisted
+
+		default:
+			return errors.Errorf("Unexpected status %d on chunk upload", resp.StatusCode)
+		}
+	}
+
+	return errors.Wrap(p.deleteResumableState(resumableStatePrefix+key), "Unable to delete upload state")
+}
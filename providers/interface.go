@@ -1,7 +1,9 @@
 package providers
 
 import (
+	"context"
 	"hash"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -12,6 +14,8 @@ const (
 	CapBasic Capability = 1 << iota
 	CapShare
 	CapAutoChecksum
+	CapWatch
+	CapMove
 )
 
 func (c Capability) Has(test Capability) bool { return c&test != 0 }
@@ -21,6 +25,20 @@ var (
 	ErrFeatureNotSupported = errors.New("Feature not supported")
 )
 
+type ShareMode string
+
+const (
+	// ShareModePublic publishes the file permanently
+	ShareModePublic ShareMode = "public"
+	// ShareModePresigned creates a link expiring after ShareOptions.Expires
+	ShareModePresigned ShareMode = "presigned"
+)
+
+type ShareOptions struct {
+	Mode    ShareMode
+	Expires time.Duration
+}
+
 type CloudProviderInitFunc func(string) (CloudProvider, error)
 
 type CloudProvider interface {
@@ -29,7 +47,10 @@ type CloudProvider interface {
 	GetChecksumMethod() hash.Hash
 	GetFile(relativeName string) (File, error)
 	ListFiles() ([]File, error)
+	MoveFile(from, to string) (File, error)
 	Name() string
 	PutFile(File) (File, error)
-	Share(relativeName string) (string, error)
+	Share(relativeName string, opts ShareOptions) (string, error)
+	Unshare(relativeName string) error
+	Watch(ctx context.Context, changes chan<- string) error
 }
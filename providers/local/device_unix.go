@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package local
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+func deviceID(info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return strconv.FormatUint(uint64(stat.Dev), 10)
+	}
+	return ""
+}
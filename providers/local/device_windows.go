@@ -0,0 +1,7 @@
+package local
+
+import "os"
+
+// deviceID is not available on Windows, an empty ID disables the
+// device check while still verifying the root exists
+func deviceID(info os.FileInfo) string { return "" }
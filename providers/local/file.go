@@ -1,7 +1,6 @@
 package local
 
 import (
-	"bytes"
 	"fmt"
 	"hash"
 	"io"
@@ -31,13 +30,13 @@ func (f File) Checksum(h hash.Hash) (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "Unable to get file contents")
 	}
+	defer fc.Close()
 
-	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, fc); err != nil {
+	if _, err := io.Copy(h, fc); err != nil {
 		return "", errors.Wrap(err, "Unable to read file contents")
 	}
 
-	return fmt.Sprintf("%x", h.Sum(buf.Bytes())), nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 func (f File) Content() (io.ReadCloser, error) {
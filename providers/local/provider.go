@@ -2,8 +2,11 @@ package local
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -15,6 +18,11 @@ import (
 	"github.com/Luzifer/cloudbox/providers"
 )
 
+const (
+	dirPermission  = 0755
+	filePermission = 0644
+)
+
 func New(uri string) (providers.CloudProvider, error) {
 	if !strings.HasPrefix(uri, "file://") {
 		return nil, providers.ErrInvalidURI
@@ -25,11 +33,37 @@ func New(uri string) (providers.CloudProvider, error) {
 
 type Provider struct {
 	directory string
+	trash     *Trash
+}
+
+// SetTrash makes the provider keep deleted and overwritten files in
+// the given trash instead of removing them permanently
+func (p *Provider) SetTrash(t *Trash) { p.trash = t }
+
+// Purge removes expired entries from the trash if enabled
+func (p Provider) Purge() error {
+	if p.trash == nil {
+		return nil
+	}
+
+	return p.trash.Purge()
 }
 
-func (p Provider) Capabilities() providers.Capability { return providers.CapBasic }
-func (p Provider) Name() string                       { return "local" }
-func (p Provider) GetChecksumMethod() hash.Hash       { return sha256.New() }
+// Restore moves the newest version of the file from the trash back
+// into the sync directory
+func (p Provider) Restore(relativeName string, overwrite bool) (TrashEntry, error) {
+	if p.trash == nil {
+		return TrashEntry{}, errors.New("Trash is not enabled")
+	}
+
+	return p.trash.restore(relativeName, path.Join(p.directory, relativeName), overwrite)
+}
+
+func (p Provider) Capabilities() providers.Capability {
+	return providers.CapBasic | providers.CapMove | providers.CapWatch
+}
+func (p Provider) Name() string                 { return "local" }
+func (p Provider) GetChecksumMethod() hash.Hash { return sha256.New() }
 
 func (p Provider) ListFiles() ([]providers.File, error) {
 	var (
@@ -47,7 +81,7 @@ func (p Provider) ListFiles() ([]providers.File, error) {
 			return err
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || isTempFile(path) {
 			// We behave like git: We don't care about dirs themselves
 			return nil
 		}
@@ -65,7 +99,19 @@ func (p Provider) ListFiles() ([]providers.File, error) {
 }
 
 func (p Provider) DeleteFile(relativeName string) error {
-	return os.Remove(path.Join(p.directory, relativeName))
+	fullPath := path.Join(p.directory, relativeName)
+
+	if p.trash != nil {
+		if err := p.trash.keep(fullPath, relativeName); err != nil {
+			return errors.Wrap(err, "Unable to move file to trash")
+		}
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		return errors.Wrap(err, "Unable to delete file")
+	}
+
+	return p.PruneEmptyParents(relativeName)
 }
 
 func (p Provider) GetFile(relativeName string) (providers.File, error) {
@@ -79,6 +125,11 @@ func (p Provider) GetFile(relativeName string) (providers.File, error) {
 		return nil, errors.Wrap(err, "Unable to get file stat")
 	}
 
+	if stat.IsDir() {
+		// We behave like git: We don't care about dirs themselves
+		return nil, providers.ErrFileNotFound
+	}
+
 	return File{
 		info:         stat,
 		relativeName: relativeName,
@@ -86,35 +137,172 @@ func (p Provider) GetFile(relativeName string) (providers.File, error) {
 	}, nil
 }
 
+func (p Provider) MoveFile(from, to string) (providers.File, error) {
+	toPath := path.Join(p.directory, to)
+
+	if err := os.MkdirAll(path.Dir(toPath), dirPermission); err != nil {
+		return nil, errors.Wrap(err, "Unable to create parent directories")
+	}
+
+	if err := os.Rename(path.Join(p.directory, from), toPath); err != nil {
+		return nil, errors.Wrap(err, "Unable to rename file")
+	}
+
+	// Parents left empty are pruned by the sync engine through
+	// PruneEmptyParents: A concurrent PutFile could be about to write
+	// into them
+	return p.GetFile(to)
+}
+
 func (p Provider) PutFile(f providers.File) (providers.File, error) {
-	fullPath := path.Join(p.directory, f.Info().RelativeName)
+	var (
+		info     = f.Info()
+		fullPath = path.Join(p.directory, info.RelativeName)
+	)
 
-	fp, err := os.Create(fullPath)
-	if err != nil {
-		return nil, errors.Wrap(err, "Unable to create file")
+	if err := os.MkdirAll(path.Dir(fullPath), dirPermission); err != nil {
+		return nil, errors.Wrap(err, "Unable to create parent directories")
 	}
 
-	rfp, err := f.Content()
+	// Write into a temp file next to the target to be able to atomically
+	// replace the target after the content is verified
+	fp, err := ioutil.TempFile(path.Dir(fullPath), tempFilePrefix+"*")
 	if err != nil {
-		return nil, errors.Wrap(err, "Unable to get remote file content")
+		return nil, errors.Wrap(err, "Unable to create temp file")
 	}
-	defer rfp.Close()
+	tempPath := fp.Name()
+	defer os.Remove(tempPath) // Noop after successful rename
 
-	if _, err := io.Copy(fp, rfp); err != nil {
-		return nil, errors.Wrap(err, "Unable to copy file contents")
+	// Temp files are created private, replicate what os.Create would do
+	var (
+		mode   os.FileMode = filePermission
+		exists bool
+	)
+	if stat, err := os.Stat(fullPath); err == nil {
+		mode = stat.Mode().Perm()
+		exists = true
+	}
+
+	if err := fp.Chmod(mode); err != nil {
+		fp.Close()
+		return nil, errors.Wrap(err, "Unable to set file permissions")
+	}
+
+	if err := p.writeVerified(fp, f); err != nil {
+		fp.Close()
+		return nil, err
 	}
 
 	if err := fp.Close(); err != nil {
 		return nil, errors.Wrap(err, "Unable to close local file")
 	}
 
-	if err := os.Chtimes(fullPath, time.Now(), f.Info().LastModified); err != nil {
+	if err := os.Chtimes(tempPath, time.Now(), info.LastModified); err != nil {
 		return nil, errors.Wrap(err, "Unable to set last file mod time")
 	}
 
-	return p.GetFile(f.Info().RelativeName)
+	if exists && p.trash != nil {
+		if err := p.trash.keep(fullPath, info.RelativeName); err != nil {
+			return nil, errors.Wrap(err, "Unable to move overwritten file to trash")
+		}
+	}
+
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		return nil, errors.Wrap(err, "Unable to move temp file into place")
+	}
+
+	return p.GetFile(info.RelativeName)
 }
 
-func (p Provider) Share(relativeName string) (string, error) {
+func (p Provider) writeVerified(fp *os.File, f providers.File) error {
+	var (
+		info           = f.Info()
+		h              = verificationHash(f)
+		w    io.Writer = fp
+	)
+
+	if h != nil {
+		w = io.MultiWriter(fp, h)
+	}
+
+	rfp, err := f.Content()
+	if err != nil {
+		return errors.Wrap(err, "Unable to get remote file content")
+	}
+	defer rfp.Close()
+
+	n, err := io.Copy(w, rfp)
+	if err != nil {
+		return errors.Wrap(err, "Unable to copy file contents")
+	}
+
+	if uint64(n) != info.Size {
+		return errors.Errorf("Size mismatch: expected %d bytes, got %d", info.Size, n)
+	}
+
+	if h != nil {
+		if sum := fmt.Sprintf("%x", h.Sum(nil)); sum != strings.ToLower(info.Checksum) {
+			return errors.Errorf("Checksum mismatch: expected %s, got %s", info.Checksum, sum)
+		}
+	}
+
+	return errors.Wrap(fp.Sync(), "Unable to sync file to disk")
+}
+
+// verificationHash returns a fresh hash of the method the checksum of
+// the file was created with. Files not providing their checksum method
+// and checksums not in the format of the method (i.e. multipart ETags)
+// yield nil and are not verified.
+func verificationHash(f providers.File) hash.Hash {
+	vf, ok := f.(providers.VerifiableFile)
+	if !ok {
+		return nil
+	}
+
+	checksum := f.Info().Checksum
+	if checksum == "" {
+		return nil
+	}
+
+	h := vf.ChecksumMethod()
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != 2*h.Size() {
+		return nil
+	}
+
+	return h
+}
+
+// PruneEmptyParents removes directories left empty after removing the
+// given file, stopping at the first non-empty one or the sync root
+func (p Provider) PruneEmptyParents(relativeName string) error {
+	for dir := path.Dir(relativeName); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		fullPath := path.Join(p.directory, dir)
+
+		entries, err := ioutil.ReadDir(fullPath)
+		switch {
+		case os.IsNotExist(err):
+			// Already pruned by a concurrent delete
+			return nil
+		case err != nil:
+			return errors.Wrap(err, "Unable to read parent directory")
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Unable to remove empty parent directory")
+		}
+	}
+
+	return nil
+}
+
+func (p Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
 	return "", providers.ErrFeatureNotSupported
 }
+
+func (p Provider) Unshare(relativeName string) error {
+	return providers.ErrFeatureNotSupported
+}
@@ -0,0 +1,213 @@
+package local
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+// newTestProvider creates a provider for a new empty directory
+func newTestProvider(t *testing.T) *Provider {
+	dir, err := ioutil.TempDir("", "cloudbox-local")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	p, err := New("file://" + dir)
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	return p.(*Provider)
+}
+
+func TestProvider(t *testing.T) {
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		return newTestProvider(t)
+	})
+}
+
+// testFile is used to put content into the provider under test
+type testFile struct {
+	relativeName string
+	checksum     string
+	content      []byte
+}
+
+func (f testFile) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: time.Now(),
+		Checksum:     f.checksum,
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f testFile) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f testFile) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func putTestFile(t *testing.T, p *Provider, relativeName string) {
+	if _, err := p.PutFile(testFile{relativeName: relativeName, content: []byte(relativeName)}); err != nil {
+		t.Fatalf("Unable to put %q: %s", relativeName, err)
+	}
+}
+
+func assertExists(t *testing.T, p *Provider, relativeName string, expected bool) {
+	_, err := os.Stat(path.Join(p.directory, relativeName))
+	if exists := err == nil; exists != expected {
+		t.Errorf("Expected existence of %q to be %v, stat error: %v", relativeName, expected, err)
+	}
+}
+
+func TestNestedPutCreatesParents(t *testing.T) {
+	p := newTestProvider(t)
+	putTestFile(t, p, "a/b/c/d/e/file.txt")
+
+	files, err := p.ListFiles()
+	if err != nil {
+		t.Fatalf("Unable to list files: %s", err)
+	}
+
+	if len(files) != 1 || files[0].Info().RelativeName != "a/b/c/d/e/file.txt" {
+		t.Errorf("Expected only the nested file to be listed, got %d files", len(files))
+	}
+}
+
+func TestNestedDeletePrunesUpToRoot(t *testing.T) {
+	p := newTestProvider(t)
+	putTestFile(t, p, "a/b/c/d/e/file.txt")
+
+	if err := p.DeleteFile("a/b/c/d/e/file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	assertExists(t, p, "a", false)
+	assertExists(t, p, "", true)
+}
+
+func TestNestedDeleteKeepsNonEmptyParents(t *testing.T) {
+	p := newTestProvider(t)
+	putTestFile(t, p, "a/b/keep.txt")
+	putTestFile(t, p, "a/b/c/d/file.txt")
+
+	if err := p.DeleteFile("a/b/c/d/file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	assertExists(t, p, "a/b/c", false)
+	assertExists(t, p, "a/b/keep.txt", true)
+
+	if err := p.DeleteFile("a/b/keep.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	assertExists(t, p, "a", false)
+	assertExists(t, p, "", true)
+}
+
+func TestNestedDeleteOfTopLevelFileKeepsRoot(t *testing.T) {
+	p := newTestProvider(t)
+	putTestFile(t, p, "file.txt")
+
+	if err := p.DeleteFile("file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	assertExists(t, p, "", true)
+}
+
+func TestPutVerifiesChecksumOfSourceMethod(t *testing.T) {
+	var (
+		content = []byte("content")
+		sum     = fmt.Sprintf("%x", md5.Sum(content))
+	)
+
+	for _, tc := range []struct {
+		name     string
+		file     providers.File
+		expectOK bool
+	}{
+		{"matching", providers.WithChecksumMethod(testFile{"file.txt", sum, content}, md5.New), true},
+		{"mismatch", providers.WithChecksumMethod(testFile{"file.txt", sum, []byte("other")}, md5.New), false},
+		{"foreign format", providers.WithChecksumMethod(testFile{"file.txt", sum + "-2", content}, md5.New), true},
+		{"unknown method", testFile{"file.txt", "0000", content}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProvider(t)
+
+			_, err := p.PutFile(tc.file)
+			if ok := err == nil; ok != tc.expectOK {
+				t.Errorf("Expected success to be %v, got error %v", tc.expectOK, err)
+			}
+
+			assertExists(t, p, "file.txt", tc.expectOK)
+		})
+	}
+}
+
+func TestMoveKeepsParentsUntilPruned(t *testing.T) {
+	p := newTestProvider(t)
+	putTestFile(t, p, "a/b/file.txt")
+
+	if _, err := p.MoveFile("a/b/file.txt", "c/file.txt"); err != nil {
+		t.Fatalf("Unable to move file: %s", err)
+	}
+
+	// A concurrent PutFile might be about to write into the source directory
+	assertExists(t, p, "a/b", true)
+
+	if err := p.PruneEmptyParents("a/b/file.txt"); err != nil {
+		t.Fatalf("Unable to prune parents: %s", err)
+	}
+
+	assertExists(t, p, "a", false)
+	assertExists(t, p, "c/file.txt", true)
+}
+
+func TestOnlyCleanupRemovesStaleTempFiles(t *testing.T) {
+	p := newTestProvider(t)
+
+	var (
+		stale = path.Join(p.directory, tempFilePrefix+"stale")
+		fresh = path.Join(p.directory, tempFilePrefix+"fresh")
+		old   = time.Now().Add(-2 * tempFileMaxAge)
+	)
+
+	for _, fullPath := range []string{stale, fresh} {
+		if err := ioutil.WriteFile(fullPath, []byte("partial"), filePermission); err != nil {
+			t.Fatalf("Unable to write temp file: %s", err)
+		}
+	}
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Unable to age temp file: %s", err)
+	}
+
+	// Commands only reading the directory create providers as well
+	if _, err := New("file://" + p.directory); err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+	assertExists(t, p, tempFilePrefix+"stale", true)
+
+	if err := p.Cleanup(); err != nil {
+		t.Fatalf("Unable to clean up: %s", err)
+	}
+	assertExists(t, p, tempFilePrefix+"stale", false)
+	assertExists(t, p, tempFilePrefix+"fresh", true)
+}
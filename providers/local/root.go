@@ -0,0 +1,23 @@
+package local
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// RootID identifies the device the sync root is located on to detect
+// an unmounted disk leaving an empty mount point behind
+func (p Provider) RootID() (string, error) {
+	info, err := os.Stat(p.directory)
+	switch {
+	case os.IsNotExist(err):
+		return "", errors.Errorf("Sync root %q does not exist", p.directory)
+	case err != nil:
+		return "", errors.Wrap(err, "Unable to get sync root stat")
+	case !info.IsDir():
+		return "", errors.Errorf("Sync root %q is not a directory", p.directory)
+	}
+
+	return deviceID(info), nil
+}
@@ -0,0 +1,46 @@
+package local
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	tempFilePrefix = ".cloudbox-tmp-"
+	// Temp files not written to for this duration are considered
+	// leftovers of an interrupted download
+	tempFileMaxAge = time.Hour
+)
+
+func isTempFile(fullPath string) bool {
+	return strings.HasPrefix(path.Base(fullPath), tempFilePrefix)
+}
+
+// Cleanup removes temp files left behind by interrupted downloads
+func (p Provider) Cleanup() error {
+	absPath, err := filepath.Abs(p.directory)
+	if err != nil {
+		return errors.Wrap(err, "Unable to calculate absolute path")
+	}
+
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !isTempFile(path) || time.Since(info.ModTime()) < tempFileMaxAge {
+			return nil
+		}
+
+		return errors.Wrapf(os.Remove(path), "Unable to remove temp file %q", path)
+	})
+}
@@ -0,0 +1,273 @@
+package local
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// Trash keeps deleted and overwritten local files for a retention
+// period instead of removing them permanently
+type Trash struct {
+	directory string
+	retention time.Duration
+
+	lastPurge time.Time
+	lock      sync.Mutex
+}
+
+// TrashEntry describes one version of a file kept in the trash
+type TrashEntry struct {
+	RelativeName string    `json:"relative_name"`
+	TrashedAt    time.Time `json:"trashed_at"`
+	Size         int64     `json:"size"`
+
+	fullPath string
+}
+
+func NewTrash(directory string, retention time.Duration) *Trash {
+	return &Trash{directory: path.Clean(directory), retention: retention}
+}
+
+// Entries lists all files in the trash ordered by name, newest first
+func (t *Trash) Entries() ([]TrashEntry, error) {
+	var entries []TrashEntry
+
+	err := filepath.Walk(t.directory, func(fullPath string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err) && fullPath == t.directory:
+			// Nothing has been trashed yet
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir():
+			return nil
+		}
+
+		if entry, ok := t.entryFromPath(fullPath, info); ok {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list trash")
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].RelativeName != entries[j].RelativeName {
+			return entries[i].RelativeName < entries[j].RelativeName
+		}
+		return entries[i].TrashedAt.After(entries[j].TrashedAt)
+	})
+
+	return entries, nil
+}
+
+func (t *Trash) entryFromPath(fullPath string, info os.FileInfo) (TrashEntry, bool) {
+	rel, err := filepath.Rel(t.directory, fullPath)
+	if err != nil {
+		return TrashEntry{}, false
+	}
+
+	relativeName, trashedAt, ok := providers.ParseTrashName(rel)
+	if !ok {
+		return TrashEntry{}, false
+	}
+
+	return TrashEntry{
+		RelativeName: filepath.ToSlash(relativeName),
+		TrashedAt:    trashedAt,
+		Size:         info.Size(),
+
+		fullPath: fullPath,
+	}, true
+}
+
+// Purge removes all entries older than the retention period, it does
+// nothing if the last purge happened less than the purge interval ago
+func (t *Trash) Purge() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.purgeIfDue()
+}
+
+func (t *Trash) purgeIfDue() error {
+	if time.Since(t.lastPurge) < providers.TrashPurgeInterval {
+		return nil
+	}
+
+	return t.purge()
+}
+
+func (t *Trash) purge() error {
+	entries, err := t.Entries()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if time.Since(entry.TrashedAt) < t.retention {
+			continue
+		}
+
+		if err := os.Remove(entry.fullPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Unable to remove expired trash entry")
+		}
+
+		if err := t.pruneEmptyParents(entry.fullPath); err != nil {
+			return err
+		}
+	}
+
+	t.lastPurge = time.Now()
+	return nil
+}
+
+// keep stores the current version of the file in the trash without
+// touching the original
+func (t *Trash) keep(fullPath, relativeName string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.purgeIfDue(); err != nil {
+		return errors.Wrap(err, "Unable to purge trash")
+	}
+
+	return t.keepLocked(fullPath, relativeName)
+}
+
+func (t *Trash) keepLocked(fullPath, relativeName string) error {
+	target := path.Join(t.directory, providers.TrashName(relativeName, time.Now()))
+
+	if err := os.MkdirAll(path.Dir(target), dirPermission); err != nil {
+		return errors.Wrap(err, "Unable to create trash directory")
+	}
+
+	// Hard links are cheap but not possible across devices
+	if err := os.Link(fullPath, target); err == nil {
+		return nil
+	}
+
+	return errors.Wrap(copyFile(fullPath, target), "Unable to copy file into trash")
+}
+
+// restore moves the newest version of the file back to the target,
+// an existing target is only replaced (and trashed) if requested
+func (t *Trash) restore(relativeName, target string, overwrite bool) (TrashEntry, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	entries, err := t.Entries()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	var entry *TrashEntry
+	for i := range entries {
+		if entries[i].RelativeName == relativeName {
+			entry = &entries[i]
+			break
+		}
+	}
+
+	if entry == nil {
+		return TrashEntry{}, errors.Errorf("File %q not found in trash", relativeName)
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		if !overwrite {
+			return TrashEntry{}, errors.Errorf("File %q exists, force the restore to replace it", relativeName)
+		}
+
+		if err := t.keepLocked(target, relativeName); err != nil {
+			return TrashEntry{}, errors.Wrap(err, "Unable to trash existing file")
+		}
+	}
+
+	if err := os.MkdirAll(path.Dir(target), dirPermission); err != nil {
+		return TrashEntry{}, errors.Wrap(err, "Unable to create parent directories")
+	}
+
+	if err := os.Rename(entry.fullPath, target); err != nil {
+		// Trash is located on another device: Copy next to the target
+		// to be able to atomically move it into place
+		tempPath := path.Join(path.Dir(target), tempFilePrefix+path.Base(target))
+		defer os.Remove(tempPath) // Noop after successful rename
+
+		if err := copyFile(entry.fullPath, tempPath); err != nil {
+			return TrashEntry{}, errors.Wrap(err, "Unable to copy file from trash")
+		}
+
+		if err := os.Rename(tempPath, target); err != nil {
+			return TrashEntry{}, errors.Wrap(err, "Unable to move restored file into place")
+		}
+
+		if err := os.Remove(entry.fullPath); err != nil {
+			return TrashEntry{}, errors.Wrap(err, "Unable to remove restored file from trash")
+		}
+	}
+
+	return *entry, t.pruneEmptyParents(entry.fullPath)
+}
+
+// pruneEmptyParents removes trash directories left empty after removing
+// the given entry
+func (t *Trash) pruneEmptyParents(fullPath string) error {
+	for dir := path.Dir(fullPath); strings.HasPrefix(dir, t.directory+"/"); dir = path.Dir(dir) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return errors.Wrap(err, "Unable to read trash directory")
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err := os.Remove(dir); err != nil {
+			return errors.Wrap(err, "Unable to remove empty trash directory")
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies content, permissions and modification time
+func copyFile(src, dst string) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return errors.Wrap(err, "Unable to get source file stat")
+	}
+
+	sfp, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "Unable to open source file")
+	}
+	defer sfp.Close()
+
+	dfp, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, stat.Mode().Perm())
+	if err != nil {
+		return errors.Wrap(err, "Unable to create target file")
+	}
+
+	if _, err := io.Copy(dfp, sfp); err != nil {
+		dfp.Close()
+		return errors.Wrap(err, "Unable to copy file contents")
+	}
+
+	if err := dfp.Close(); err != nil {
+		return errors.Wrap(err, "Unable to close target file")
+	}
+
+	return errors.Wrap(os.Chtimes(dst, time.Now(), stat.ModTime()), "Unable to set last file mod time")
+}
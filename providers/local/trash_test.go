@@ -0,0 +1,137 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// newTestTrash attaches a trash in a new empty directory to the provider
+func newTestTrash(t *testing.T, p *Provider, retention time.Duration) *Trash {
+	dir, err := ioutil.TempDir("", "cloudbox-trash")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	trash := NewTrash(dir, retention)
+	p.SetTrash(trash)
+	return trash
+}
+
+func assertTrashEntries(t *testing.T, trash *Trash, expected int) {
+	entries, err := trash.Entries()
+	if err != nil {
+		t.Fatalf("Unable to list trash: %s", err)
+	}
+
+	if len(entries) != expected {
+		t.Errorf("Expected %d trash entries, got %d", expected, len(entries))
+	}
+}
+
+func TestTrashEntriesDoNotPurge(t *testing.T) {
+	p := newTestProvider(t)
+	trash := newTestTrash(t, p, time.Nanosecond)
+
+	putTestFile(t, p, "file.txt")
+	if err := p.DeleteFile("file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	// The entry is expired but listing must keep it
+	assertTrashEntries(t, trash, 1)
+	assertTrashEntries(t, trash, 1)
+}
+
+func TestPurgeRemovesExpiredEntries(t *testing.T) {
+	p := newTestProvider(t)
+	trash := newTestTrash(t, p, time.Nanosecond)
+
+	putTestFile(t, p, "a/file.txt")
+	if err := p.DeleteFile("a/file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	// Trashing the file purged the trash right before
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+	assertTrashEntries(t, trash, 1)
+
+	trash.lastPurge = time.Time{}
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+	assertTrashEntries(t, trash, 0)
+}
+
+func TestPurgeKeepsEntriesWithinRetention(t *testing.T) {
+	p := newTestProvider(t)
+	trash := newTestTrash(t, p, time.Hour)
+
+	putTestFile(t, p, "file.txt")
+	if err := p.DeleteFile("file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	trash.lastPurge = time.Time{}
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+	assertTrashEntries(t, trash, 1)
+}
+
+func TestPurgeRemovesOnlyEntriesOlderThanRetention(t *testing.T) {
+	p := newTestProvider(t)
+	trash := newTestTrash(t, p, 24*time.Hour)
+
+	var (
+		now  = time.Now()
+		kept = []string{
+			providers.TrashName("recent.txt", now.Add(-time.Hour)),
+			providers.TrashName("dir/recent.txt", now.Add(-23*time.Hour)),
+			// Not created by trashing a file
+			"unknown.txt",
+		}
+		expired = []string{
+			providers.TrashName("expired.txt", now.Add(-48*time.Hour)),
+			providers.TrashName("dir/expired.txt", now.Add(-25*time.Hour)),
+			providers.TrashName("old/expired.txt", now.Add(-25*time.Hour)),
+		}
+	)
+
+	for _, name := range append(kept, expired...) {
+		fullPath := filepath.Join(trash.directory, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0700); err != nil {
+			t.Fatalf("Unable to create directory: %s", err)
+		}
+		if err := ioutil.WriteFile(fullPath, []byte("content"), 0600); err != nil {
+			t.Fatalf("Unable to write trash entry: %s", err)
+		}
+	}
+
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(trash.directory, name)); err != nil {
+			t.Errorf("Expected %q to be kept: %s", name, err)
+		}
+	}
+
+	for _, name := range expired {
+		if _, err := os.Stat(filepath.Join(trash.directory, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %q to be purged, got %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(trash.directory, "old")); !os.IsNotExist(err) {
+		t.Errorf("Expected directory left empty to be pruned, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+func (p Provider) Watch(ctx context.Context, changes chan<- string) error {
+	absPath, err := filepath.Abs(p.directory)
+	if err != nil {
+		return errors.Wrap(err, "Unable to calculate absolute path")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "Unable to create watcher")
+	}
+	defer watcher.Close()
+
+	emit := func(fullPath string) bool {
+		select {
+		case changes <- strings.TrimLeft(strings.TrimPrefix(fullPath, absPath), "/"):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// fsnotify does not watch recursively so every directory needs to be added
+	addTree := func(root string, emitFiles bool) error {
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() {
+				if emitFiles {
+					emit(path)
+				}
+				return nil
+			}
+
+			return errors.Wrapf(watcher.Add(path), "Unable to watch directory %q", path)
+		})
+	}
+
+	if err := addTree(absPath, false); err != nil {
+		return errors.Wrap(err, "Unable to set up watches")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if evt.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(evt.Name); err == nil && info.IsDir() {
+					// Directory created or moved in: Watch it and report its contents
+					if err := addTree(evt.Name, true); err != nil {
+						return errors.Wrap(err, "Unable to watch new directory")
+					}
+					continue
+				}
+			}
+
+			if isTempFile(evt.Name) {
+				// Our own in-flight downloads
+				continue
+			}
+
+			if !emit(evt.Name) {
+				return nil
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return errors.Wrap(err, "Watcher failed")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type File struct {
+	relativeName string
+	lastModified time.Time
+	checksum     string
+	content      []byte
+}
+
+func (f File) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: f.lastModified,
+		Checksum:     f.checksum,
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f File) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f File) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// Provider keeps all files in memory. It is meant for tests and dry
+// experiments: Everything is lost when the process exits.
+type Provider struct {
+	files map[string]File
+	lock  sync.RWMutex
+}
+
+func New(uri string) (providers.CloudProvider, error) {
+	if !strings.HasPrefix(uri, "memory://") {
+		return nil, providers.ErrInvalidURI
+	}
+
+	return &Provider{files: map[string]File{}}, nil
+}
+
+func (p *Provider) Capabilities() providers.Capability {
+	return providers.CapBasic | providers.CapAutoChecksum | providers.CapMove
+}
+func (p *Provider) Name() string                 { return "memory" }
+func (p *Provider) GetChecksumMethod() hash.Hash { return sha256.New() }
+
+func (p *Provider) DeleteFile(relativeName string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.files[relativeName]; !ok {
+		return providers.ErrFileNotFound
+	}
+
+	delete(p.files, relativeName)
+	return nil
+}
+
+func (p *Provider) GetFile(relativeName string) (providers.File, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	f, ok := p.files[relativeName]
+	if !ok {
+		return nil, providers.ErrFileNotFound
+	}
+
+	return f, nil
+}
+
+func (p *Provider) ListFiles() ([]providers.File, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var files []providers.File
+	for _, f := range p.files {
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Info().RelativeName < files[j].Info().RelativeName })
+
+	return files, nil
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	f, ok := p.files[from]
+	if !ok {
+		return nil, providers.ErrFileNotFound
+	}
+
+	f.relativeName = to
+	p.files[to] = f
+	delete(p.files, from)
+
+	return f, nil
+}
+
+func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	info := f.Info()
+
+	body, err := f.Content()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to get file reader")
+	}
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read source file")
+	}
+
+	nf := File{
+		relativeName: info.RelativeName,
+		lastModified: info.LastModified,
+		checksum:     fmt.Sprintf("%x", sha256.Sum256(content)),
+		content:      content,
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.files[info.RelativeName] = nf
+	return nf, nil
+}
+
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	return "", providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Unshare(relativeName string) error {
+	return providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
+}
@@ -0,0 +1,18 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+func TestProvider(t *testing.T) {
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		p, err := New("memory://")
+		if err != nil {
+			t.Fatalf("Unable to create provider: %s", err)
+		}
+		return p
+	})
+}
@@ -0,0 +1,41 @@
+package providerstest
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// sourceFile is the file handed to the provider under test, it behaves
+// like a file of a provider without CapAutoChecksum
+type sourceFile struct {
+	relativeName string
+	lastModified time.Time
+	content      []byte
+}
+
+func newSourceFile(relativeName string, content []byte, lastModified time.Time) sourceFile {
+	return sourceFile{relativeName: relativeName, lastModified: lastModified, content: content}
+}
+
+func (f sourceFile) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: f.lastModified,
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f sourceFile) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f sourceFile) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
@@ -0,0 +1,367 @@
+// Package providerstest contains a conformance suite verifying a
+// providers.CloudProvider implementation behaves like all others:
+//
+//	func TestProvider(t *testing.T) {
+//		providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+//			dir, err := ioutil.TempDir("", "cloudbox")
+//			...
+//			p, err := local.New("file://" + dir)
+//			...
+//			return p
+//		})
+//	}
+//
+// The factory is called for every test and must return a provider
+// pointing to an empty location.
+package providerstest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// Factory creates a provider pointing to an empty location
+type Factory func(t *testing.T) providers.CloudProvider
+
+// testTime is used as modification time for put files. Some providers
+// only store seconds so it must not contain fractions of a second.
+var testTime = time.Date(2019, 7, 1, 12, 30, 45, 0, time.UTC)
+
+// Run executes all conformance tests against providers created by
+// the given factory
+func Run(t *testing.T, newProvider Factory) {
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, providers.CloudProvider)
+	}{
+		{"Capabilities", testCapabilities},
+		{"Checksum", testChecksum},
+		{"Delete", testDelete},
+		{"List", testList},
+		{"Move", testMove},
+		{"MtimeRoundTrip", testMtimeRoundTrip},
+		{"NestedPaths", testNestedPaths},
+		{"NotFound", testNotFound},
+		{"Overwrite", testOverwrite},
+		{"PutGet", testPutGet},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) { tc.fn(t, newProvider(t)) })
+	}
+}
+
+func testCapabilities(t *testing.T, p providers.CloudProvider) {
+	caps := p.Capabilities()
+
+	if !caps.Has(providers.CapBasic) {
+		t.Error("Provider does not have CapBasic")
+	}
+
+	if p.Name() == "" {
+		t.Error("Provider has no name")
+	}
+
+	if p.GetChecksumMethod() == nil {
+		t.Error("Provider has no checksum method")
+	}
+
+	used := p.GetChecksumMethod()
+	used.Write([]byte("used"))
+	if fresh := p.GetChecksumMethod(); fmt.Sprintf("%x", fresh.Sum(nil)) == fmt.Sprintf("%x", used.Sum(nil)) {
+		t.Error("GetChecksumMethod must return a fresh hash on every call")
+	}
+
+	if !caps.Has(providers.CapShare) {
+		if _, err := p.Share("file.txt", providers.ShareOptions{}); errors.Cause(err) != providers.ErrFeatureNotSupported {
+			t.Errorf("Share without CapShare returned %v, expected ErrFeatureNotSupported", err)
+		}
+
+		if err := p.Unshare("file.txt"); errors.Cause(err) != providers.ErrFeatureNotSupported {
+			t.Errorf("Unshare without CapShare returned %v, expected ErrFeatureNotSupported", err)
+		}
+	}
+
+	if !caps.Has(providers.CapMove) {
+		if _, err := p.MoveFile("file.txt", "moved.txt"); errors.Cause(err) != providers.ErrFeatureNotSupported {
+			t.Errorf("MoveFile without CapMove returned %v, expected ErrFeatureNotSupported", err)
+		}
+	}
+
+	if !caps.Has(providers.CapWatch) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := p.Watch(ctx, make(chan string)); errors.Cause(err) != providers.ErrFeatureNotSupported {
+			t.Errorf("Watch without CapWatch returned %v, expected ErrFeatureNotSupported", err)
+		}
+	}
+}
+
+func testChecksum(t *testing.T, p providers.CloudProvider) {
+	content := []byte("checksum content")
+	f := put(t, p, "checksum.txt", content)
+
+	h := p.GetChecksumMethod()
+	h.Write(content)
+	expected := fmt.Sprintf("%x", h.Sum(nil))
+
+	for i := 0; i < 2; i++ {
+		// Checksum must be stable over multiple calls
+		sum, err := f.Checksum(p.GetChecksumMethod())
+		if err != nil {
+			t.Fatalf("Checksum failed: %s", err)
+		}
+
+		if sum != expected {
+			t.Errorf("Checksum returned %q, expected %q", sum, expected)
+		}
+	}
+
+	if !p.Capabilities().Has(providers.CapAutoChecksum) {
+		return
+	}
+
+	got := get(t, p, "checksum.txt")
+	if got.Info().Checksum == "" {
+		t.Fatal("Provider has CapAutoChecksum but file info has no checksum")
+	}
+
+	for _, lf := range list(t, p) {
+		if lf.Info().RelativeName == "checksum.txt" && lf.Info().Checksum != got.Info().Checksum {
+			t.Errorf("Checksum from listing %q differs from GetFile %q", lf.Info().Checksum, got.Info().Checksum)
+		}
+	}
+
+	put(t, p, "checksum.txt", []byte("changed checksum content"))
+	if changed := get(t, p, "checksum.txt"); changed.Info().Checksum == got.Info().Checksum {
+		t.Error("Checksum in file info did not change with the content")
+	}
+}
+
+func testDelete(t *testing.T, p providers.CloudProvider) {
+	put(t, p, "delete.txt", []byte("delete me"))
+	put(t, p, "keep.txt", []byte("keep me"))
+
+	if err := p.DeleteFile("delete.txt"); err != nil {
+		t.Fatalf("DeleteFile failed: %s", err)
+	}
+
+	if _, err := p.GetFile("delete.txt"); errors.Cause(err) != providers.ErrFileNotFound {
+		t.Errorf("GetFile of deleted file returned %v, expected ErrFileNotFound", err)
+	}
+
+	assertNames(t, p, []string{"keep.txt"})
+}
+
+func testList(t *testing.T, p providers.CloudProvider) {
+	assertNames(t, p, nil)
+
+	files := map[string][]byte{
+		"a.txt":         []byte("a"),
+		"b.txt":         []byte("bb"),
+		"with space.md": []byte("ccc"),
+	}
+
+	var names []string
+	for name, content := range files {
+		put(t, p, name, content)
+		names = append(names, name)
+	}
+
+	assertNames(t, p, names)
+
+	for _, f := range list(t, p) {
+		info := f.Info()
+		if info.Size != uint64(len(files[info.RelativeName])) {
+			t.Errorf("Listed size of %q is %d, expected %d", info.RelativeName, info.Size, len(files[info.RelativeName]))
+		}
+	}
+}
+
+func testMove(t *testing.T, p providers.CloudProvider) {
+	if !p.Capabilities().Has(providers.CapMove) {
+		t.Skip("Provider does not have CapMove")
+	}
+
+	content := []byte("move me")
+	src := put(t, p, "from/move.txt", content)
+
+	f, err := p.MoveFile("from/move.txt", "to/nested/moved.txt")
+	if err != nil {
+		t.Fatalf("MoveFile failed: %s", err)
+	}
+
+	if f.Info().RelativeName != "to/nested/moved.txt" {
+		t.Errorf("MoveFile returned name %q, expected %q", f.Info().RelativeName, "to/nested/moved.txt")
+	}
+
+	if f.Info().Checksum != src.Info().Checksum || !f.Info().LastModified.Equal(src.Info().LastModified) {
+		t.Errorf("MoveFile changed file info from %+v to %+v", src.Info(), f.Info())
+	}
+
+	if _, err := p.GetFile("from/move.txt"); errors.Cause(err) != providers.ErrFileNotFound {
+		t.Errorf("GetFile of moved file returned %v, expected ErrFileNotFound", err)
+	}
+
+	assertContent(t, get(t, p, "to/nested/moved.txt"), content)
+	assertNames(t, p, []string{"to/nested/moved.txt"})
+}
+
+func testMtimeRoundTrip(t *testing.T, p providers.CloudProvider) {
+	f := put(t, p, "mtime.txt", []byte("mtime"))
+	if !f.Info().LastModified.Equal(testTime) {
+		t.Errorf("PutFile returned modification time %s, expected %s", f.Info().LastModified, testTime)
+	}
+
+	if got := get(t, p, "mtime.txt"); !got.Info().LastModified.Equal(testTime) {
+		t.Errorf("GetFile returned modification time %s, expected %s", got.Info().LastModified, testTime)
+	}
+
+	for _, lf := range list(t, p) {
+		if !lf.Info().LastModified.Equal(testTime) {
+			t.Errorf("Listing returned modification time %s, expected %s", lf.Info().LastModified, testTime)
+		}
+	}
+}
+
+func testNestedPaths(t *testing.T, p providers.CloudProvider) {
+	content := []byte("nested")
+	put(t, p, "a/b/c/nested.txt", content)
+	put(t, p, "a/sibling.txt", content)
+
+	// Directories themselves are not files
+	assertNames(t, p, []string{"a/b/c/nested.txt", "a/sibling.txt"})
+	assertContent(t, get(t, p, "a/b/c/nested.txt"), content)
+
+	if err := p.DeleteFile("a/b/c/nested.txt"); err != nil {
+		t.Fatalf("DeleteFile failed: %s", err)
+	}
+
+	assertNames(t, p, []string{"a/sibling.txt"})
+}
+
+func testNotFound(t *testing.T, p providers.CloudProvider) {
+	if _, err := p.GetFile("missing.txt"); errors.Cause(err) != providers.ErrFileNotFound {
+		t.Errorf("GetFile of missing file returned %v, expected ErrFileNotFound", err)
+	}
+
+	put(t, p, "dir/file.txt", []byte("file"))
+
+	if _, err := p.GetFile("dir"); errors.Cause(err) != providers.ErrFileNotFound {
+		t.Errorf("GetFile of directory returned %v, expected ErrFileNotFound", err)
+	}
+
+	if _, err := p.GetFile("dir/missing.txt"); errors.Cause(err) != providers.ErrFileNotFound {
+		t.Errorf("GetFile of missing nested file returned %v, expected ErrFileNotFound", err)
+	}
+}
+
+func testOverwrite(t *testing.T, p providers.CloudProvider) {
+	put(t, p, "overwrite.txt", []byte("original content"))
+
+	replaced := []byte("new")
+	f := put(t, p, "overwrite.txt", replaced)
+
+	if f.Info().Size != uint64(len(replaced)) {
+		t.Errorf("PutFile returned size %d, expected %d", f.Info().Size, len(replaced))
+	}
+
+	assertContent(t, get(t, p, "overwrite.txt"), replaced)
+	assertNames(t, p, []string{"overwrite.txt"})
+}
+
+func testPutGet(t *testing.T, p providers.CloudProvider) {
+	content := []byte("Hello World")
+	f := put(t, p, "hello.txt", content)
+
+	if f.Info().RelativeName != "hello.txt" {
+		t.Errorf("PutFile returned name %q, expected %q", f.Info().RelativeName, "hello.txt")
+	}
+
+	if f.Info().Size != uint64(len(content)) {
+		t.Errorf("PutFile returned size %d, expected %d", f.Info().Size, len(content))
+	}
+
+	got := get(t, p, "hello.txt")
+	if got.Info().RelativeName != "hello.txt" {
+		t.Errorf("GetFile returned name %q, expected %q", got.Info().RelativeName, "hello.txt")
+	}
+
+	assertContent(t, got, content)
+}
+
+func put(t *testing.T, p providers.CloudProvider, relativeName string, content []byte) providers.File {
+	t.Helper()
+
+	f, err := p.PutFile(newSourceFile(relativeName, content, testTime))
+	if err != nil {
+		t.Fatalf("PutFile of %q failed: %s", relativeName, err)
+	}
+
+	return f
+}
+
+func get(t *testing.T, p providers.CloudProvider, relativeName string) providers.File {
+	t.Helper()
+
+	f, err := p.GetFile(relativeName)
+	if err != nil {
+		t.Fatalf("GetFile of %q failed: %s", relativeName, err)
+	}
+
+	return f
+}
+
+func list(t *testing.T, p providers.CloudProvider) []providers.File {
+	t.Helper()
+
+	files, err := p.ListFiles()
+	if err != nil {
+		t.Fatalf("ListFiles failed: %s", err)
+	}
+
+	return files
+}
+
+func assertContent(t *testing.T, f providers.File, expected []byte) {
+	t.Helper()
+
+	r, err := f.Content()
+	if err != nil {
+		t.Fatalf("Content failed: %s", err)
+	}
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading content failed: %s", err)
+	}
+
+	if string(content) != string(expected) {
+		t.Errorf("Content is %q, expected %q", content, expected)
+	}
+}
+
+func assertNames(t *testing.T, p providers.CloudProvider, expected []string) {
+	t.Helper()
+
+	var names []string
+	for _, f := range list(t, p) {
+		names = append(names, f.Info().RelativeName)
+	}
+
+	sort.Strings(names)
+	sort.Strings(expected)
+
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("Listing contains %v, expected %v", names, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// maxCopySize is the maximum object size supported by CopyObject and
+// the maximum part size of multipart copies
+const maxCopySize = 5 << 30
+
+// copyObject copies an object within the bucket keeping its metadata
+// and applying the given ACL to the copy. Objects too large for
+// CopyObject are copied in parts.
+func (p *Provider) copyObject(srcKey, dstKey string, size int64, acl string) error {
+	copySource := aws.String((&url.URL{Path: p.bucket + "/" + srcKey}).EscapedPath())
+
+	if size > maxCopySize {
+		return p.copyMultipart(srcKey, dstKey, copySource, size, acl)
+	}
+
+	_, err := p.s3.CopyObject(&s3.CopyObjectInput{
+		ACL:        aws.String(acl),
+		Bucket:     aws.String(p.bucket),
+		CopySource: copySource,
+		Key:        aws.String(dstKey),
+	})
+	return errors.Wrap(err, "Unable to copy object")
+}
+
+func (p *Provider) copyMultipart(srcKey, dstKey string, copySource *string, size int64, acl string) error {
+	// Multipart uploads do not take over the metadata of the source
+	head, err := p.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Unable to fetch head information")
+	}
+
+	upload, err := p.s3.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		ACL:         aws.String(acl),
+		Bucket:      aws.String(p.bucket),
+		ContentType: head.ContentType,
+		Key:         aws.String(dstKey),
+		Metadata:    head.Metadata,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Unable to create multipart upload")
+	}
+
+	var parts []*s3.CompletedPart
+	for partNo, offset := int64(1), int64(0); offset < size; partNo, offset = partNo+1, offset+maxCopySize {
+		end := offset + maxCopySize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		out, err := p.s3.UploadPartCopy(&s3.UploadPartCopyInput{
+			Bucket:          aws.String(p.bucket),
+			CopySource:      copySource,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+			Key:             aws.String(dstKey),
+			PartNumber:      aws.Int64(partNo),
+			UploadId:        upload.UploadId,
+		})
+		if err != nil {
+			// Failed aborts are cleaned up as orphaned uploads later
+			p.abortUpload(dstKey, *upload.UploadId)
+			return errors.Wrapf(err, "Unable to copy part %d", partNo)
+		}
+
+		parts = append(parts, &s3.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int64(partNo)})
+	}
+
+	if _, err = p.s3.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(p.bucket),
+		Key:             aws.String(dstKey),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		UploadId:        upload.UploadId,
+	}); err != nil {
+		p.abortUpload(dstKey, *upload.UploadId)
+		return errors.Wrap(err, "Unable to complete multipart copy")
+	}
+
+	return nil
+}
@@ -0,0 +1,478 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeS3 is a minimal in-memory S3 implementation supporting the calls
+// used by the provider. Buckets are addressed path style or virtual
+// hosted style, authentication is not checked.
+type fakeS3 struct {
+	bucket string
+
+	lock     sync.Mutex
+	objects  map[string]*fakeObject
+	uploads  map[string]*fakeUpload
+	requests []fakeRequest
+	uploadNo int
+
+	// failParts lets uploads of the given part numbers fail
+	failParts map[int]bool
+}
+
+type fakeObject struct {
+	content      []byte
+	etag         string
+	acl          string
+	meta         http.Header
+	lastModified time.Time
+}
+
+type fakeUpload struct {
+	key       string
+	acl       string
+	meta      http.Header
+	parts     map[int][]byte
+	initiated time.Time
+}
+
+// fakeRequest records how a request addressed the bucket
+type fakeRequest struct {
+	Method string
+	Host   string
+	Key    string
+	Query  url.Values
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: map[string]*fakeObject{},
+		uploads: map[string]*fakeUpload{},
+	}
+}
+
+func (f *fakeS3) countRequests(method string, query string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var n int
+	for _, r := range f.requests {
+		if r.Method == method && (query == "" || r.Query[query] != nil) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeS3) lastRequest() fakeRequest {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.requests[len(f.requests)-1]
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var (
+		hostBucket = strings.HasPrefix(r.Host, f.bucket+".")
+		key        string
+	)
+
+	switch {
+	case hostBucket:
+		key = strings.TrimPrefix(r.URL.Path, "/")
+	case r.URL.Path == "/"+f.bucket || strings.HasPrefix(r.URL.Path, "/"+f.bucket+"/"):
+		key = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+f.bucket), "/")
+	default:
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	query := r.URL.Query()
+	f.requests = append(f.requests, fakeRequest{Method: r.Method, Host: r.Host, Key: key, Query: query})
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && query["uploads"] != nil:
+		f.listUploads(w)
+	case key == "" && r.Method == http.MethodGet:
+		f.listObjects(w, query.Get("prefix"))
+	case key == "" && r.Method == http.MethodPost && query["delete"] != nil:
+		f.deleteObjects(w, body)
+
+	case r.Method == http.MethodPost && query["uploads"] != nil:
+		f.createUpload(w, r, key)
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		f.uploadPart(w, r, key, body)
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		f.completeUpload(w, query.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && query.Get("uploadId") != "":
+		f.listParts(w, query.Get("uploadId"))
+
+	case r.Method == http.MethodGet && query["acl"] != nil:
+		f.getACL(w, key)
+	case r.Method == http.MethodPut && query["acl"] != nil:
+		f.putACL(w, r, key)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copyObject(w, r, key)
+	case r.Method == http.MethodPut:
+		f.objects[key] = newFakeObject(body, r.Header.Get("X-Amz-Acl"), r.Header)
+		w.Header().Set("ETag", f.objects[key].etag)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		f.getObject(w, r, key)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func newFakeObject(content []byte, acl string, header http.Header) *fakeObject {
+	sum := md5.Sum(content)
+	return &fakeObject{
+		content:      content,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		acl:          acl,
+		meta:         metaFromHeader(header),
+		lastModified: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func metaFromHeader(header http.Header) http.Header {
+	meta := http.Header{}
+	for k, v := range header {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+func (f *fakeS3) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	for k, v := range obj.meta {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.content)))
+
+	if r.Method == http.MethodGet {
+		w.Write(obj.content)
+	}
+}
+
+func (f *fakeS3) copySource(r *http.Request) (*fakeObject, bool) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return nil, false
+	}
+
+	obj, ok := f.objects[strings.TrimPrefix(strings.TrimPrefix(source, "/"), f.bucket+"/")]
+	return obj, ok
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, r *http.Request, key string) {
+	src, ok := f.copySource(r)
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	obj := newFakeObject(src.content, r.Header.Get("X-Amz-Acl"), src.meta)
+	f.objects[key] = obj
+
+	writeFakeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: obj.etag, LastModified: obj.lastModified.Format(time.RFC3339)})
+}
+
+func (f *fakeS3) listObjects(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		ETag         string
+		Size         int
+		LastModified string
+	}
+
+	var contents []content
+	for key, obj := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			contents = append(contents, content{key, obj.etag, len(obj.content), obj.lastModified.Format(time.RFC3339)})
+		}
+	}
+	sort.Slice(contents, func(i, j int) bool { return contents[i].Key < contents[j].Key })
+
+	writeFakeXML(w, struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		IsTruncated bool
+		Contents    []content
+	}{Name: f.bucket, Prefix: prefix, Contents: contents})
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Object []struct{ Key string }
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeFakeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	for _, obj := range req.Object {
+		delete(f.objects, obj.Key)
+	}
+
+	writeFakeXML(w, struct {
+		XMLName xml.Name `xml:"DeleteResult"`
+	}{})
+}
+
+func (f *fakeS3) getACL(w http.ResponseWriter, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	type grant struct {
+		Grantee struct {
+			URI string `xml:",omitempty"`
+		}
+		Permission string
+	}
+
+	var grants []grant
+	if obj.acl == "public-read" {
+		g := grant{Permission: "READ"}
+		g.Grantee.URI = "http://acs.amazonaws.com/groups/global/AllUsers"
+		grants = append(grants, g)
+	}
+
+	writeFakeXML(w, struct {
+		XMLName           xml.Name `xml:"AccessControlPolicy"`
+		AccessControlList struct {
+			Grant []grant
+		}
+	}{AccessControlList: struct{ Grant []grant }{grants}})
+}
+
+func (f *fakeS3) putACL(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	obj.acl = r.Header.Get("X-Amz-Acl")
+}
+
+func (f *fakeS3) createUpload(w http.ResponseWriter, r *http.Request, key string) {
+	f.uploadNo++
+	uploadID := fmt.Sprintf("upload-%d", f.uploadNo)
+	f.uploads[uploadID] = &fakeUpload{
+		key:       key,
+		acl:       r.Header.Get("X-Amz-Acl"),
+		meta:      metaFromHeader(r.Header),
+		parts:     map[int][]byte{},
+		initiated: time.Now(),
+	}
+
+	writeFakeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: f.bucket, Key: key, UploadId: uploadID})
+}
+
+func (f *fakeS3) uploadPart(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	query := r.URL.Query()
+
+	upload, ok := f.uploads[query.Get("uploadId")]
+	if !ok || upload.key != key {
+		writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	partNo, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	if f.failParts[partNo] {
+		writeFakeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	if r.Header.Get("X-Amz-Copy-Source") == "" {
+		upload.parts[partNo] = body
+		w.Header().Set("ETag", partETag(body))
+		return
+	}
+
+	src, ok := f.copySource(r)
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	var start, end int
+	if _, err := fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end); err != nil || end >= len(src.content) {
+		writeFakeError(w, http.StatusBadRequest, "InvalidRange")
+		return
+	}
+
+	upload.parts[partNo] = src.content[start : end+1]
+	writeFakeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyPartResult"`
+		ETag         string
+		LastModified string
+	}{ETag: partETag(upload.parts[partNo]), LastModified: time.Now().UTC().Format(time.RFC3339)})
+}
+
+func partETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) completeUpload(w http.ResponseWriter, uploadID string, body []byte) {
+	upload, ok := f.uploads[uploadID]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var req struct {
+		Part []struct {
+			PartNumber int
+			ETag       string
+		}
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeFakeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	var (
+		content  []byte
+		partSums []byte
+	)
+	for _, part := range req.Part {
+		data, ok := upload.parts[part.PartNumber]
+		if !ok || partETag(data) != part.ETag {
+			writeFakeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+
+		content = append(content, data...)
+		sum := md5.Sum(data)
+		partSums = append(partSums, sum[:]...)
+	}
+
+	obj := newFakeObject(content, upload.acl, upload.meta)
+	sum := md5.Sum(partSums)
+	obj.etag = fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(req.Part))
+
+	f.objects[upload.key] = obj
+	delete(f.uploads, uploadID)
+
+	writeFakeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: f.bucket, Key: upload.key, ETag: obj.etag})
+}
+
+func (f *fakeS3) listParts(w http.ResponseWriter, uploadID string) {
+	upload, ok := f.uploads[uploadID]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	type part struct {
+		PartNumber int
+		ETag       string
+		Size       int
+	}
+
+	var parts []part
+	for partNo, data := range upload.parts {
+		parts = append(parts, part{partNo, partETag(data), len(data)})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	writeFakeXML(w, struct {
+		XMLName     xml.Name `xml:"ListPartsResult"`
+		UploadId    string
+		IsTruncated bool
+		Part        []part
+	}{UploadId: uploadID, Part: parts})
+}
+
+func (f *fakeS3) listUploads(w http.ResponseWriter) {
+	type upload struct {
+		Key       string
+		UploadId  string
+		Initiated string
+	}
+
+	var uploads []upload
+	for id, up := range f.uploads {
+		uploads = append(uploads, upload{up.key, id, up.initiated.UTC().Format(time.RFC3339)})
+	}
+
+	writeFakeXML(w, struct {
+		XMLName     xml.Name `xml:"ListMultipartUploadsResult"`
+		Bucket      string
+		IsTruncated bool
+		Upload      []upload
+	}{Bucket: f.bucket, Upload: uploads})
+}
+
+func writeFakeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
@@ -1,7 +1,6 @@
 package s3
 
 import (
-	"bytes"
 	"fmt"
 	"hash"
 	"io"
@@ -42,12 +41,11 @@ func (f File) Checksum(h hash.Hash) (string, error) {
 	}
 	defer cont.Close()
 
-	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, cont); err != nil {
+	if _, err := io.Copy(h, cont); err != nil {
 		return "", errors.Wrap(err, "Unable to read file content")
 	}
 
-	return fmt.Sprintf("%x", h.Sum(buf.Bytes())), nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 func (f File) Content() (io.ReadCloser, error) {
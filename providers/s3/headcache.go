@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+const headCacheStatePrefix = "head:"
+
+// headCacheEntry keeps the metadata fetched by HEAD for an object, it
+// stays valid as long as ETag, size and modification time of the object
+// in the listing do not change. Uploads of identical content keep the
+// ETag but might carry another source modification time.
+type headCacheEntry struct {
+	ETag           string    `json:"etag"`
+	Size           int64     `json:"size"`
+	ObjectModified time.Time `json:"object_modified"`
+	Checksum       string    `json:"checksum"`
+	LastModified   time.Time `json:"last_modified"`
+}
+
+// getHeadCache returns the cached metadata for the listed object or
+// nil if the object changed or is not cached
+func (p *Provider) getHeadCache(obj *s3.Object) (*headCacheEntry, error) {
+	if p.state == nil {
+		return nil, nil
+	}
+
+	raw, err := p.state.GetState(headCacheStatePrefix + aws.StringValue(obj.Key))
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	entry := &headCacheEntry{}
+	if err = json.Unmarshal([]byte(raw), entry); err != nil {
+		// Broken entries are replaced by a fresh HEAD
+		return nil, nil
+	}
+
+	if entry.ETag != aws.StringValue(obj.ETag) || entry.Size != aws.Int64Value(obj.Size) || !entry.ObjectModified.Equal(aws.TimeValue(obj.LastModified)) {
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+func (p *Provider) setHeadCache(obj *s3.Object, f File) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(headCacheEntry{
+		ETag:           aws.StringValue(obj.ETag),
+		Size:           aws.Int64Value(obj.Size),
+		ObjectModified: aws.TimeValue(obj.LastModified),
+		Checksum:       f.checksum,
+		LastModified:   f.lastModified,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode head cache")
+	}
+
+	return errors.Wrap(p.state.SetState(headCacheStatePrefix+aws.StringValue(obj.Key), string(raw)), "Unable to store head cache")
+}
+
+// deleteHeadCache drops the cached metadata of an object being written
+// or removed: Listings can't be trusted to reveal every change
+func (p *Provider) deleteHeadCache(relativeName string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	return errors.Wrap(p.state.DeleteState(headCacheStatePrefix+*p.relativeNameToKey(relativeName)), "Unable to delete head cache")
+}
+
+// pruneHeadCache removes cached metadata of objects no longer listed
+func (p *Provider) pruneHeadCache(listed map[string]bool) error {
+	if p.state == nil {
+		return nil
+	}
+
+	keys, err := p.state.ListStateKeys()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list head cache")
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, headCacheStatePrefix) || listed[strings.TrimPrefix(key, headCacheStatePrefix)] {
+			continue
+		}
+
+		if err := p.state.DeleteState(key); err != nil {
+			return errors.Wrap(err, "Unable to delete head cache")
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) fileFromHeadCache(key string, entry *headCacheEntry) File {
+	return File{
+		key:          key,
+		lastModified: entry.LastModified,
+		checksum:     entry.Checksum,
+		size:         uint64(entry.Size),
+
+		s3Conn: p.s3,
+		bucket: p.bucket,
+		prefix: p.prefix,
+	}
+}
@@ -0,0 +1,57 @@
+package s3
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	// metaContentMD5 stores the MD5 of the whole object content as the
+	// ETag of multipart uploads is not the MD5 of the content
+	metaContentMD5 = "Cloudbox-Content-Md5"
+	// metaMtime stores the modification time of the source file as the
+	// object LastModified is the time of the upload
+	metaMtime = "Cloudbox-Mtime"
+)
+
+func getMetadata(meta map[string]*string, key string) string {
+	// Header names are canonicalized by the SDK, don't rely on the case
+	for k, v := range meta {
+		if strings.EqualFold(k, key) && v != nil {
+			return *v
+		}
+	}
+	return ""
+}
+
+// checksumFor prefers the content checksum stored by cloudbox over
+// the ETag which only is a content MD5 for plain single part uploads
+func checksumFor(etag *string, meta map[string]*string) string {
+	if sum := getMetadata(meta, metaContentMD5); sum != "" {
+		return sum
+	}
+	return strings.Trim(aws.StringValue(etag), `"`)
+}
+
+// lastModifiedFor prefers the source modification time stored by
+// cloudbox over the upload time of the object
+func lastModifiedFor(objectLastModified *time.Time, meta map[string]*string) time.Time {
+	if mtime, err := time.Parse(time.RFC3339Nano, getMetadata(meta, metaMtime)); err == nil {
+		return mtime
+	}
+
+	// HEAD requests only report seconds while listings of some S3
+	// compatible stores include milliseconds
+	return aws.TimeValue(objectLastModified).Truncate(time.Second)
+}
+
+func objectMetadata(info providers.FileInfo, contentMD5 string) map[string]*string {
+	return map[string]*string{
+		metaContentMD5: aws.String(contentMD5),
+		metaMtime:      aws.String(info.LastModified.UTC().Format(time.RFC3339Nano)),
+	}
+}
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/tls"
 	"fmt"
 	"hash"
 	"io"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -20,12 +26,25 @@ import (
 	"github.com/Luzifer/cloudbox/providers"
 )
 
+// maxPresignExpiry is the maximum lifetime of SigV4 presigned URLs
+const maxPresignExpiry = 7 * 24 * time.Hour
+
 type Provider struct {
 	bucket       string
 	bucketRegion string
+	concurrency  int
 	defaultACL   string
+	endpoint     *url.URL
+	partSize     int64
+	pathStyle    bool
 	prefix       string
 	s3           *s3.S3
+	state        providers.StateStore
+
+	trashPrefix    string
+	trashRetention time.Duration
+	lastPurge      time.Time
+	purgeLock      sync.Mutex
 }
 
 func New(uri string) (providers.CloudProvider, error) {
@@ -38,12 +57,46 @@ func New(uri string) (providers.CloudProvider, error) {
 		return nil, errors.Wrap(err, "Invalid URI specified")
 	}
 
-	region, err := s3manager.GetBucketRegion(context.Background(), session.New(), u.Host, "us-east-1")
-	if err != nil {
-		return nil, errors.Wrap(err, "Unable to find bucket region")
+	var (
+		endpoint  *url.URL
+		pathStyle = u.Query().Get("path_style") == "true"
+		region    = u.Query().Get("region")
+	)
+
+	if ep := u.Query().Get("endpoint"); ep != "" {
+		if !strings.Contains(ep, "://") {
+			ep = "https://" + ep
+		}
+
+		if endpoint, err = url.Parse(ep); err != nil {
+			return nil, errors.Wrap(err, "Invalid endpoint specified")
+		}
+
+		if region == "" {
+			// S3 compatible stores mostly don't care about regions but the SDK requires one
+			region = "us-east-1"
+		}
+	}
+
+	if region == "" {
+		if region, err = s3manager.GetBucketRegion(context.Background(), session.New(), u.Host, "us-east-1"); err != nil {
+			return nil, errors.Wrap(err, "Unable to find bucket region")
+		}
 	}
 
 	cfg := aws.NewConfig().WithRegion(region)
+	if endpoint != nil {
+		cfg = cfg.WithEndpoint(endpoint.String()).WithS3ForcePathStyle(pathStyle)
+	}
+
+	if u.Query().Get("insecure") == "true" {
+		cfg = cfg.WithHTTPClient(&http.Client{Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			// #nosec G402 - Explicitly requested by the user for self-signed certificates
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}})
+	}
+
 	if u.User != nil {
 		user := u.User.Username()
 		pass, _ := u.User.Password()
@@ -56,7 +109,11 @@ func New(uri string) (providers.CloudProvider, error) {
 	p := &Provider{
 		bucket:       u.Host,
 		bucketRegion: region,
+		concurrency:  defaultConcurrency,
 		defaultACL:   s3.ObjectCannedACLPrivate,
+		endpoint:     endpoint,
+		partSize:     defaultPartSize,
+		pathStyle:    pathStyle,
 		prefix:       strings.Trim(u.Path, "/"),
 		s3:           svc,
 	}
@@ -65,16 +122,44 @@ func New(uri string) (providers.CloudProvider, error) {
 		p.defaultACL = acl
 	}
 
+	if v := u.Query().Get("part_size"); v != "" {
+		if p.partSize, err = strconv.ParseInt(v, 10, 64); err != nil || p.partSize < minPartSize {
+			return nil, errors.Errorf("Invalid part_size, needs to be at least %d bytes", minPartSize)
+		}
+	}
+
+	if v := u.Query().Get("concurrency"); v != "" {
+		if p.concurrency, err = strconv.Atoi(v); err != nil || p.concurrency < 1 {
+			return nil, errors.New("Invalid concurrency, needs to be a positive integer")
+		}
+	}
+
+	if err = p.configureTrash(u.Query()); err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
 
 func (p *Provider) Capabilities() providers.Capability {
-	return providers.CapBasic | providers.CapAutoChecksum | providers.CapShare
+	return providers.CapBasic | providers.CapAutoChecksum | providers.CapMove | providers.CapShare
 }
 func (p *Provider) Name() string                 { return "s3" }
 func (p *Provider) GetChecksumMethod() hash.Hash { return md5.New() }
 
 func (p *Provider) DeleteFile(relativeName string) error {
+	if err := p.keepInTrash(relativeName); err != nil {
+		return err
+	}
+
+	return p.deleteObject(relativeName)
+}
+
+func (p *Provider) deleteObject(relativeName string) error {
+	if err := p.deleteHeadCache(relativeName); err != nil {
+		return err
+	}
+
 	_, err := p.s3.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(p.bucket),
 		Key:    p.relativeNameToKey(relativeName),
@@ -89,63 +174,168 @@ func (p *Provider) GetFile(relativeName string) (providers.File, error) {
 		Key:    p.relativeNameToKey(relativeName),
 	})
 	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, providers.ErrFileNotFound
+		}
 		return nil, errors.Wrap(err, "Unable to fetch head information")
 	}
 
+	return p.fileFromHead(*p.relativeNameToKey(relativeName), resp), nil
+}
+
+func (p *Provider) fileFromHead(key string, resp *s3.HeadObjectOutput) File {
 	return File{
-		key:          relativeName,
-		lastModified: *resp.LastModified,
-		checksum:     strings.Trim(*resp.ETag, `"`),
+		key:          key,
+		lastModified: lastModifiedFor(resp.LastModified, resp.Metadata),
+		checksum:     checksumFor(resp.ETag, resp.Metadata),
 		size:         uint64(*resp.ContentLength),
 
 		s3Conn: p.s3,
 		bucket: p.bucket,
-	}, nil
+		prefix: p.prefix,
+	}
 }
 
 func (p *Provider) ListFiles() ([]providers.File, error) {
-	var files []providers.File
+	var (
+		objects []*s3.Object
+		listed  = map[string]bool{}
+	)
 
 	err := p.s3.ListObjectsPages(&s3.ListObjectsInput{
 		Bucket: aws.String(p.bucket),
 		Prefix: aws.String(p.prefix),
 	}, func(out *s3.ListObjectsOutput, lastPage bool) bool {
 		for _, obj := range out.Contents {
-			files = append(files, File{
-				key:          *obj.Key,
-				lastModified: *obj.LastModified,
-				checksum:     strings.Trim(*obj.ETag, `"`),
-				size:         uint64(*obj.Size),
-
-				s3Conn: p.s3,
-				bucket: p.bucket,
-				prefix: p.prefix,
-			})
+			if p.isTrashKey(*obj.Key) {
+				continue
+			}
+			objects = append(objects, obj)
+			listed[*obj.Key] = true
 		}
 
 		return !lastPage
 	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list objects")
+	}
+
+	// Listing does not contain metadata (content checksum, modification
+	// time) so it needs to be fetched for every object not cached
+	var (
+		files   = make([]providers.File, len(objects))
+		headErr error
+		lock    = new(sync.Mutex)
+		sem     = make(chan struct{}, p.concurrency)
+		wg      = new(sync.WaitGroup)
+	)
+
+	for i, obj := range objects {
+		entry, err := p.getHeadCache(obj)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to read head cache")
+		}
+
+		if entry != nil {
+			files[i] = p.fileFromHeadCache(*obj.Key, entry)
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, obj *s3.Object) {
+			defer func() { <-sem; wg.Done() }()
+
+			resp, err := p.s3.HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(p.bucket),
+				Key:    obj.Key,
+			})
+			if err == nil {
+				f := p.fileFromHead(*obj.Key, resp)
+				files[i] = f
+				err = p.setHeadCache(obj, f)
+			}
+
+			if err != nil {
+				lock.Lock()
+				headErr = errors.Wrapf(err, "Unable to fetch head information for %q", *obj.Key)
+				lock.Unlock()
+			}
+		}(i, obj)
+	}
+
+	wg.Wait()
+	if headErr != nil {
+		return nil, headErr
+	}
 
-	return files, errors.Wrap(err, "Unable to list objects")
+	return files, errors.Wrap(p.pruneHeadCache(listed), "Unable to prune head cache")
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	f, err := p.GetFile(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.deleteHeadCache(to); err != nil {
+		return nil, err
+	}
+
+	// Metadata (content checksum, modification time) is copied along
+	// with the object, the ACL is not: Shares do not follow a move
+	if err = p.copyObject(*p.relativeNameToKey(from), *p.relativeNameToKey(to), int64(f.Info().Size), p.defaultACL); err != nil {
+		return nil, err
+	}
+
+	// Content still exists under the new name, no need for the trash
+	if err = p.deleteObject(from); err != nil {
+		return nil, err
+	}
+
+	return p.GetFile(to)
 }
 
 func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	if p.isTrashName(f.Info().RelativeName) {
+		return nil, errors.New("Path is reserved for the trash")
+	}
+
+	if err := p.keepInTrash(f.Info().RelativeName); err != nil {
+		return nil, err
+	}
+
+	if err := p.deleteHeadCache(f.Info().RelativeName); err != nil {
+		return nil, err
+	}
+
+	if int64(f.Info().Size) >= p.partSize {
+		if err := p.putMultipart(f); err != nil {
+			return nil, errors.Wrap(err, "Unable to write file")
+		}
+
+		return p.GetFile(f.Info().RelativeName)
+	}
+
 	body, err := f.Content()
 	if err != nil {
 		return nil, errors.Wrap(err, "Unable to get file reader")
 	}
 	defer body.Close()
 
+	// Files smaller than one part are buffered in memory
 	buf := new(bytes.Buffer)
 	if _, err := io.Copy(buf, body); err != nil {
 		return nil, errors.Wrap(err, "Unable to read source file")
 	}
 
 	if _, err = p.s3.PutObject(&s3.PutObjectInput{
-		ACL:    aws.String(p.getFileACL(f.Info().RelativeName)),
-		Body:   bytes.NewReader(buf.Bytes()),
-		Bucket: aws.String(p.bucket),
-		Key:    p.relativeNameToKey(f.Info().RelativeName),
+		ACL:      aws.String(p.getFileACL(f.Info().RelativeName)),
+		Body:     bytes.NewReader(buf.Bytes()),
+		Bucket:   aws.String(p.bucket),
+		Key:      p.relativeNameToKey(f.Info().RelativeName),
+		Metadata: objectMetadata(f.Info(), fmt.Sprintf("%x", md5.Sum(buf.Bytes()))),
 	}); err != nil {
 		return nil, errors.Wrap(err, "Unable to write file")
 	}
@@ -153,7 +343,32 @@ func (p *Provider) PutFile(f providers.File) (providers.File, error) {
 	return p.GetFile(f.Info().RelativeName)
 }
 
-func (p *Provider) Share(relativeName string) (string, error) {
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	switch opts.Mode {
+	case providers.ShareModePresigned:
+		return p.sharePresigned(relativeName, opts.Expires)
+	case "", providers.ShareModePublic:
+		return p.sharePublic(relativeName)
+	default:
+		return "", providers.ErrFeatureNotSupported
+	}
+}
+
+func (p *Provider) sharePresigned(relativeName string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > maxPresignExpiry {
+		return "", errors.Errorf("Expiry must be between 0 and %s", maxPresignExpiry)
+	}
+
+	req, _ := p.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    p.relativeNameToKey(relativeName),
+	})
+
+	shareURL, err := req.Presign(expires)
+	return shareURL, errors.Wrap(err, "Unable to presign URL")
+}
+
+func (p *Provider) sharePublic(relativeName string) (string, error) {
 	_, err := p.s3.PutObjectAcl(&s3.PutObjectAclInput{
 		ACL:    aws.String(s3.ObjectCannedACLPublicRead),
 		Bucket: aws.String(p.bucket),
@@ -163,7 +378,24 @@ func (p *Provider) Share(relativeName string) (string, error) {
 		return "", errors.Wrap(err, "Unable to publish file")
 	}
 
-	return fmt.Sprintf("https://s3-%s.amazonaws.com/%s/%s", p.bucketRegion, p.bucket, relativeName), nil
+	return p.objectURL(*p.relativeNameToKey(relativeName)), nil
+}
+
+func (p *Provider) Unshare(relativeName string) error {
+	_, err := p.s3.PutObjectAcl(&s3.PutObjectAclInput{
+		ACL:    aws.String(s3.ObjectCannedACLPrivate),
+		Bucket: aws.String(p.bucket),
+		Key:    p.relativeNameToKey(relativeName),
+	})
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return providers.ErrFileNotFound
+	}
+
+	return errors.Wrap(err, "Unable to unpublish file")
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
 }
 
 func (p *Provider) getFileACL(relativeName string) string {
@@ -188,7 +420,22 @@ func (p *Provider) getFileACL(relativeName string) string {
 	return p.defaultACL
 }
 
-func (p Provider) relativeNameToKey(relativeName string) *string {
+func (p *Provider) objectURL(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+
+	switch {
+	case p.endpoint == nil:
+		return fmt.Sprintf("https://s3-%s.amazonaws.com/%s/%s", p.bucketRegion, p.bucket, escapedKey)
+
+	case p.pathStyle:
+		return fmt.Sprintf("%s/%s/%s", strings.TrimRight(p.endpoint.String(), "/"), p.bucket, escapedKey)
+
+	default:
+		return fmt.Sprintf("%s://%s.%s/%s", p.endpoint.Scheme, p.bucket, p.endpoint.Host, escapedKey)
+	}
+}
+
+func (p *Provider) relativeNameToKey(relativeName string) *string {
 	key := strings.Join([]string{p.prefix, relativeName}, "/")
 	return &key
 }
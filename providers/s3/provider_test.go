@@ -0,0 +1,430 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+// newTestProvider creates a provider for the bucket "test" served by a
+// new fake S3 server, options are appended to the URI
+func newTestProvider(t *testing.T, options string) (*Provider, *fakeS3) {
+	fake := newFakeS3("test")
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New("s3://key:secret@test/prefix?endpoint=" + server.URL + "&path_style=true" + options)
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	return p.(*Provider), fake
+}
+
+func TestProvider(t *testing.T) {
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		p, _ := newTestProvider(t, "")
+		return p
+	})
+}
+
+func TestProviderWithTrash(t *testing.T) {
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		p, _ := newTestProvider(t, "&trash=.trash")
+		return p
+	})
+}
+
+func TestTrashCopiesArePrivate(t *testing.T) {
+	p, fake := newTestProvider(t, "&trash=.trash&acl=public-read")
+	putTestFile(t, p, "file.txt")
+	putTestFile(t, p, "moved.txt")
+
+	if _, err := p.MoveFile("moved.txt", "dir/moved.txt"); err != nil {
+		t.Fatalf("Unable to move file: %s", err)
+	}
+
+	if acl := fake.objects["prefix/dir/moved.txt"].acl; acl != "public-read" {
+		t.Errorf("Expected moved object to keep the default ACL, got %q", acl)
+	}
+
+	if err := p.DeleteFile("file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+
+	var trashed int
+	for key, obj := range fake.objects {
+		if !strings.HasPrefix(key, "prefix/.trash/") {
+			continue
+		}
+
+		trashed++
+		if obj.acl != "private" {
+			t.Errorf("Expected trashed object %q to be private, got ACL %q", key, obj.acl)
+		}
+	}
+
+	if trashed != 1 {
+		t.Errorf("Expected one trashed object, got %d", trashed)
+	}
+}
+
+// testFile is used to put content into the provider under test
+type testFile struct {
+	relativeName string
+	lastModified time.Time
+	content      []byte
+}
+
+func (f testFile) Info() providers.FileInfo {
+	lastModified := f.lastModified
+	if lastModified.IsZero() {
+		lastModified = time.Date(2019, 7, 1, 12, 30, 45, 0, time.UTC)
+	}
+
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: lastModified,
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f testFile) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f testFile) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func putTestFile(t *testing.T, p providers.CloudProvider, relativeName string) {
+	if _, err := p.PutFile(testFile{relativeName: relativeName, content: []byte("content")}); err != nil {
+		t.Fatalf("Unable to put %q: %s", relativeName, err)
+	}
+}
+
+func TestPathStyleEndpoint(t *testing.T) {
+	p, fake := newTestProvider(t, "")
+	putTestFile(t, p, "dir/file.txt")
+
+	req := fake.lastRequest()
+	if req.Host != p.endpoint.Host || req.Key != "prefix/dir/file.txt" {
+		t.Errorf("Expected path style request to %s for key prefix/dir/file.txt, got %+v", p.endpoint.Host, req)
+	}
+
+	if p.bucketRegion != "us-east-1" {
+		t.Errorf("Expected default region for custom endpoints, got %q", p.bucketRegion)
+	}
+}
+
+func TestVirtualHostedEndpoint(t *testing.T) {
+	fake := newFakeS3("test")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := New("s3://key:secret@test/prefix?endpoint=http://s3.example.com&region=eu-central-1")
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	// Bucket subdomains of the endpoint do not resolve: Connect all
+	// requests to the fake server
+	p.(*Provider).s3.Config.HTTPClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	putTestFile(t, p, "file.txt")
+
+	if req := fake.lastRequest(); req.Host != "test.s3.example.com" || req.Key != "prefix/file.txt" {
+		t.Errorf("Expected virtual hosted request to test.s3.example.com for key prefix/file.txt, got %+v", req)
+	}
+
+	if p.(*Provider).bucketRegion != "eu-central-1" {
+		t.Errorf("Expected configured region, got %q", p.(*Provider).bucketRegion)
+	}
+}
+
+func TestInsecureEndpoint(t *testing.T) {
+	fake := newFakeS3("test")
+	server := httptest.NewUnstartedServer(fake)
+	// Rejected handshakes are expected
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	uri := "s3://key:secret@test/prefix?endpoint=" + server.URL + "&path_style=true"
+
+	p, err := New(uri)
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	if _, err = p.ListFiles(); err == nil {
+		t.Error("Expected self-signed certificate to be rejected")
+	}
+
+	if p, err = New(uri + "&insecure=true"); err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	if _, err = p.ListFiles(); err != nil {
+		t.Errorf("Expected self-signed certificate to be accepted: %s", err)
+	}
+}
+
+func TestEndpointWithoutScheme(t *testing.T) {
+	p, err := New("s3://key:secret@test/?endpoint=s3.example.com")
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	if ep := p.(*Provider).endpoint.String(); ep != "https://s3.example.com" {
+		t.Errorf("Expected endpoint to default to https, got %q", ep)
+	}
+}
+
+func TestObjectURL(t *testing.T) {
+	for _, tc := range []struct {
+		uri      string
+		expected string
+	}{
+		{"s3://test/prefix?region=eu-central-1", "https://s3-eu-central-1.amazonaws.com/test/prefix/dir/a%20file.txt"},
+		{"s3://test/prefix?endpoint=https://s3.example.com&path_style=true", "https://s3.example.com/test/prefix/dir/a%20file.txt"},
+		{"s3://test/prefix?endpoint=https://s3.example.com", "https://test.s3.example.com/prefix/dir/a%20file.txt"},
+	} {
+		p, err := New(tc.uri)
+		if err != nil {
+			t.Fatalf("Unable to create provider for %q: %s", tc.uri, err)
+		}
+
+		pp := p.(*Provider)
+		if url := pp.objectURL(*pp.relativeNameToKey("dir/a file.txt")); url != tc.expected {
+			t.Errorf("URI %q: expected object URL %q, got %q", tc.uri, tc.expected, url)
+		}
+	}
+}
+
+func TestSharePublic(t *testing.T) {
+	p, fake := newTestProvider(t, "")
+	putTestFile(t, p, "file.txt")
+
+	shareURL, err := p.Share("file.txt", providers.ShareOptions{Mode: providers.ShareModePublic})
+	if err != nil {
+		t.Fatalf("Unable to share file: %s", err)
+	}
+
+	if expected := p.endpoint.String() + "/test/prefix/file.txt"; shareURL != expected {
+		t.Errorf("Expected share URL %q, got %q", expected, shareURL)
+	}
+
+	if acl := fake.objects["prefix/file.txt"].acl; acl != "public-read" {
+		t.Errorf("Expected shared object to be public, got ACL %q", acl)
+	}
+
+	if err = p.Unshare("file.txt"); err != nil {
+		t.Fatalf("Unable to unshare file: %s", err)
+	}
+
+	if acl := fake.objects["prefix/file.txt"].acl; acl != "private" {
+		t.Errorf("Expected unshared object to be private, got ACL %q", acl)
+	}
+}
+
+func TestSharePresigned(t *testing.T) {
+	p, _ := newTestProvider(t, "&region=eu-central-1")
+	putTestFile(t, p, "file.txt")
+
+	shareURL, err := p.Share("file.txt", providers.ShareOptions{Mode: providers.ShareModePresigned, Expires: time.Hour})
+	if err != nil {
+		t.Fatalf("Unable to share file: %s", err)
+	}
+
+	u, err := url.Parse(shareURL)
+	if err != nil {
+		t.Fatalf("Share URL is invalid: %s", err)
+	}
+
+	if u.Host != p.endpoint.Host || u.Path != "/test/prefix/file.txt" {
+		t.Errorf("Expected share URL to point to the object, got %q", shareURL)
+	}
+
+	q := u.Query()
+	if q.Get("X-Amz-Expires") != "3600" || q.Get("X-Amz-Signature") == "" ||
+		!strings.Contains(q.Get("X-Amz-Credential"), "/eu-central-1/s3/") {
+		t.Errorf("Expected presigned URL valid for one hour in eu-central-1, got %q", shareURL)
+	}
+
+	resp, err := http.Get(shareURL)
+	if err != nil {
+		t.Fatalf("Unable to fetch share URL: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected share URL to be fetchable, got status %d", resp.StatusCode)
+	}
+
+	if _, err = p.Share("file.txt", providers.ShareOptions{Mode: providers.ShareModePresigned, Expires: 8 * 24 * time.Hour}); err == nil {
+		t.Error("Expected expiry beyond the SigV4 limit to be rejected")
+	}
+}
+
+// memoryStateStore keeps provider state for the duration of a test,
+// it is used concurrently like the database backed store
+type memoryStateStore struct {
+	lock   sync.Mutex
+	values map[string]string
+}
+
+func newMemoryStateStore(values map[string]string) *memoryStateStore {
+	if values == nil {
+		values = map[string]string{}
+	}
+	return &memoryStateStore{values: values}
+}
+
+func (m *memoryStateStore) DeleteState(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.values, key)
+	return nil
+}
+
+func (m *memoryStateStore) GetState(key string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.values[key], nil
+}
+
+func (m *memoryStateStore) SetState(key, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryStateStore) ListStateKeys() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var keys []string
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func TestProviderWithStateStore(t *testing.T) {
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		p, _ := newTestProvider(t, "")
+		if err := p.SetStateStore(newMemoryStateStore(nil)); err != nil {
+			t.Fatalf("Unable to set state store: %s", err)
+		}
+		return p
+	})
+}
+
+func TestListFilesCachesHeads(t *testing.T) {
+	p, fake := newTestProvider(t, "")
+	store := newMemoryStateStore(nil)
+	if err := p.SetStateStore(store); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		putTestFile(t, p, name)
+	}
+
+	assertHeads := func(expected int) {
+		t.Helper()
+
+		before := fake.countRequests(http.MethodHead, "")
+		files, err := p.ListFiles()
+		if err != nil {
+			t.Fatalf("Unable to list files: %s", err)
+		}
+
+		if len(files) != 3 {
+			t.Errorf("Expected 3 files, got %d", len(files))
+		}
+
+		for _, f := range files {
+			if f.Info().Checksum == "" || !f.Info().LastModified.Equal(testFile{}.Info().LastModified) {
+				t.Errorf("Expected metadata for %q, got %+v", f.Info().RelativeName, f.Info())
+			}
+		}
+
+		if heads := fake.countRequests(http.MethodHead, "") - before; heads != expected {
+			t.Errorf("Expected %d HEAD requests, got %d", expected, heads)
+		}
+	}
+
+	assertHeads(3)
+	assertHeads(0)
+
+	if _, err := p.PutFile(testFile{relativeName: "b.txt", content: []byte("changed")}); err != nil {
+		t.Fatalf("Unable to overwrite file: %s", err)
+	}
+	assertHeads(1)
+
+	if err := p.DeleteFile("c.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+	putTestFile(t, p, "d.txt")
+	assertHeads(1)
+
+	if v, _ := store.GetState(headCacheStatePrefix + "prefix/c.txt"); v != "" {
+		t.Error("Expected head cache of deleted object to be pruned")
+	}
+}
+
+func TestListFilesNoticesReuploadWithNewMtime(t *testing.T) {
+	p, _ := newTestProvider(t, "")
+	if err := p.SetStateStore(newMemoryStateStore(nil)); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	var (
+		content = []byte("content")
+		mtimes  = []time.Time{
+			time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC),
+			time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC),
+		}
+	)
+
+	for _, mtime := range mtimes {
+		if _, err := p.PutFile(testFile{relativeName: "a.txt", lastModified: mtime, content: content}); err != nil {
+			t.Fatalf("Unable to put file: %s", err)
+		}
+
+		files, err := p.ListFiles()
+		if err != nil {
+			t.Fatalf("Unable to list files: %s", err)
+		}
+
+		if len(files) != 1 || !files[0].Info().LastModified.Equal(mtime) {
+			t.Errorf("Expected listing to contain modification time %s, got %+v", mtime, files)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package s3
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	// maxDeleteObjects is the maximum number of keys per DeleteObjects call
+	maxDeleteObjects = 1000
+)
+
+// configureTrash enables keeping deleted and overwritten objects under
+// the trash prefix instead of removing them permanently
+func (p *Provider) configureTrash(query url.Values) error {
+	p.trashPrefix = strings.Trim(query.Get("trash"), "/")
+	p.trashRetention = defaultTrashRetention
+
+	if v := query.Get("trash_retention"); v != "" {
+		var err error
+		if p.trashRetention, err = time.ParseDuration(v); err != nil || p.trashRetention <= 0 {
+			return errors.New("Invalid trash_retention, needs to be a positive duration")
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) isTrashKey(key string) bool {
+	return p.trashPrefix != "" && strings.HasPrefix(key, *p.relativeNameToKey(p.trashPrefix)+"/")
+}
+
+func (p *Provider) isTrashName(relativeName string) bool {
+	return p.trashPrefix != "" && strings.HasPrefix(relativeName+"/", p.trashPrefix+"/")
+}
+
+// keepInTrash copies the current version of the object into the trash
+// before it gets deleted or overwritten
+func (p *Provider) keepInTrash(relativeName string) error {
+	if p.trashPrefix == "" {
+		return nil
+	}
+
+	f, err := p.GetFile(relativeName)
+	switch {
+	case err == providers.ErrFileNotFound:
+		// Nothing to keep
+		return nil
+	case err != nil:
+		return errors.Wrap(err, "Unable to get object to trash")
+	}
+
+	trashKey := *p.relativeNameToKey(providers.TrashName(path.Join(p.trashPrefix, relativeName), time.Now()))
+
+	// Trashed objects must never be public, even with a public default ACL
+	return errors.Wrap(
+		p.copyObject(*p.relativeNameToKey(relativeName), trashKey, int64(f.Info().Size), s3.ObjectCannedACLPrivate),
+		"Unable to copy object into trash",
+	)
+}
+
+// Purge removes objects from the trash after the retention period, it
+// does nothing if the last purge happened less than the purge interval ago
+func (p *Provider) Purge() error {
+	if p.trashPrefix == "" {
+		return nil
+	}
+
+	p.purgeLock.Lock()
+	defer p.purgeLock.Unlock()
+
+	if time.Since(p.lastPurge) < providers.TrashPurgeInterval {
+		return nil
+	}
+
+	var expired []*s3.ObjectIdentifier
+	err := p.s3.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(*p.relativeNameToKey(p.trashPrefix) + "/"),
+	}, func(out *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, obj := range out.Contents {
+			_, trashedAt, ok := providers.ParseTrashName(*obj.Key)
+			if !ok || time.Since(trashedAt) < p.trashRetention {
+				continue
+			}
+
+			expired = append(expired, &s3.ObjectIdentifier{Key: obj.Key})
+		}
+
+		return !lastPage
+	})
+	if err != nil {
+		return errors.Wrap(err, "Unable to list trash")
+	}
+
+	for len(expired) > 0 {
+		n := len(expired)
+		if n > maxDeleteObjects {
+			n = maxDeleteObjects
+		}
+
+		out, err := p.s3.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(p.bucket),
+			Delete: &s3.Delete{Objects: expired[:n], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return errors.Wrap(err, "Unable to delete expired trash objects")
+		}
+
+		if len(out.Errors) > 0 {
+			return errors.Errorf("Unable to delete %d expired trash objects: %s",
+				len(out.Errors), aws.StringValue(out.Errors[0].Message))
+		}
+
+		expired = expired[n:]
+	}
+
+	p.lastPurge = time.Now()
+	return nil
+}
@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+func TestPurgeRemovesOnlyExpiredTrashObjects(t *testing.T) {
+	p, fake := newTestProvider(t, "&trash=.trash&trash_retention=24h")
+
+	var (
+		now  = time.Now()
+		kept = []string{
+			providers.TrashName("prefix/.trash/recent.txt", now.Add(-time.Hour)),
+			providers.TrashName("prefix/.trash/dir/recent.txt", now.Add(-23*time.Hour)),
+			// Not created by trashing a file
+			"prefix/.trash/unknown.txt",
+			// Outside the trash
+			providers.TrashName("prefix/file.txt", now.Add(-48*time.Hour)),
+		}
+		expired = []string{
+			providers.TrashName("prefix/.trash/dir/expired.txt", now.Add(-25*time.Hour)),
+		}
+	)
+
+	// More than fit into a single DeleteObjects call
+	for i := 0; i < maxDeleteObjects; i++ {
+		expired = append(expired, providers.TrashName(fmt.Sprintf("prefix/.trash/expired%d.txt", i), now.Add(-48*time.Hour)))
+	}
+
+	for _, key := range append(kept, expired...) {
+		fake.objects[key] = newFakeObject([]byte("content"), "private", nil)
+	}
+
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+
+	for _, key := range kept {
+		if _, ok := fake.objects[key]; !ok {
+			t.Errorf("Expected %q to be kept", key)
+		}
+	}
+
+	for _, key := range expired {
+		if _, ok := fake.objects[key]; ok {
+			t.Errorf("Expected %q to be purged", key)
+		}
+	}
+
+	if n := fake.countRequests("POST", "delete"); n != 2 {
+		t.Errorf("Expected expired objects to be deleted in 2 batches, got %d", n)
+	}
+}
+
+func TestPurgeWaitsForPurgeInterval(t *testing.T) {
+	p, fake := newTestProvider(t, "&trash=.trash&trash_retention=24h")
+
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+
+	key := providers.TrashName("prefix/.trash/expired.txt", time.Now().Add(-48*time.Hour))
+	fake.objects[key] = newFakeObject([]byte("content"), "private", nil)
+
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+
+	if _, ok := fake.objects[key]; !ok {
+		t.Fatal("Expected no purge within the purge interval")
+	}
+
+	p.lastPurge = time.Time{}
+	if err := p.Purge(); err != nil {
+		t.Fatalf("Unable to purge: %s", err)
+	}
+
+	if _, ok := fake.objects[key]; ok {
+		t.Error("Expected expired object to be purged after the purge interval")
+	}
+}
@@ -0,0 +1,325 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5" // #nosec G501 - MD5 is used as content checksum, not for security
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultPartSize    = 16 * 1024 * 1024
+	maxParts           = 10000
+	minPartSize        = 5 * 1024 * 1024
+	// Untracked uploads younger than this might belong to another
+	// client currently uploading and are not aborted
+	orphanedUploadMinAge = 24 * time.Hour
+
+	multipartStatePrefix = "multipart:"
+)
+
+type multipartState struct {
+	UploadID     string    `json:"upload_id"`
+	PartSize     int64     `json:"part_size"`
+	Size         uint64    `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+func (p *Provider) SetStateStore(store providers.StateStore) error {
+	p.state = store
+	return errors.Wrap(p.abortOrphanedUploads(), "Unable to abort orphaned uploads")
+}
+
+func (p *Provider) abortOrphanedUploads() error {
+	keys, err := p.state.ListStateKeys()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list tracked uploads")
+	}
+
+	tracked := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, multipartStatePrefix) {
+			continue
+		}
+
+		ms, err := p.getMultipartState(key)
+		if err != nil {
+			return errors.Wrap(err, "Unable to read tracked upload")
+		}
+		tracked[ms.UploadID] = true
+	}
+
+	var orphaned []*s3.MultipartUpload
+	if err = p.s3.ListMultipartUploadsPages(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(p.prefix),
+	}, func(out *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, up := range out.Uploads {
+			if tracked[*up.UploadId] || time.Since(*up.Initiated) < orphanedUploadMinAge {
+				continue
+			}
+			orphaned = append(orphaned, up)
+		}
+		return !lastPage
+	}); err != nil {
+		return errors.Wrap(err, "Unable to list multipart uploads")
+	}
+
+	for _, up := range orphaned {
+		if err := p.abortUpload(*up.Key, *up.UploadId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) abortUpload(key, uploadID string) error {
+	_, err := p.s3.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(p.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return errors.Wrap(err, "Unable to abort multipart upload")
+}
+
+func (p *Provider) getMultipartState(stateKey string) (*multipartState, error) {
+	if p.state == nil {
+		return nil, nil
+	}
+
+	raw, err := p.state.GetState(stateKey)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	ms := &multipartState{}
+	return ms, errors.Wrap(json.Unmarshal([]byte(raw), ms), "Unable to decode upload state")
+}
+
+func (p *Provider) setMultipartState(stateKey string, ms multipartState) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(ms)
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode upload state")
+	}
+
+	return p.state.SetState(stateKey, string(raw))
+}
+
+func (p *Provider) deleteMultipartState(stateKey string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	return p.state.DeleteState(stateKey)
+}
+
+func (p *Provider) listUploadedParts(key, uploadID string) (map[int64]*s3.CompletedPart, error) {
+	parts := map[int64]*s3.CompletedPart{}
+
+	err := p.s3.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(p.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(out *s3.ListPartsOutput, lastPage bool) bool {
+		for _, part := range out.Parts {
+			parts[*part.PartNumber] = &s3.CompletedPart{ETag: part.ETag, PartNumber: part.PartNumber}
+		}
+		return !lastPage
+	})
+
+	return parts, errors.Wrap(err, "Unable to list uploaded parts")
+}
+
+// resumeOrCreateUpload continues a previously interrupted upload of
+// the same file or starts a new multipart upload
+func (p *Provider) resumeOrCreateUpload(key string, info providers.FileInfo, partSize int64, metadata map[string]*string) (string, map[int64]*s3.CompletedPart, error) {
+	stateKey := multipartStatePrefix + key
+
+	ms, err := p.getMultipartState(stateKey)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Unable to read upload state")
+	}
+
+	if ms != nil {
+		if ms.Size == info.Size && ms.LastModified.Equal(info.LastModified) && ms.PartSize == partSize {
+			if done, err := p.listUploadedParts(key, ms.UploadID); err == nil {
+				return ms.UploadID, done, nil
+			}
+			// Upload vanished (i.e. was aborted): Start over
+		} else if err := p.abortUpload(key, ms.UploadID); err != nil {
+			return "", nil, errors.Wrap(err, "Unable to abort outdated upload")
+		}
+
+		if err := p.deleteMultipartState(stateKey); err != nil {
+			return "", nil, errors.Wrap(err, "Unable to delete upload state")
+		}
+	}
+
+	out, err := p.s3.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		ACL:      aws.String(p.getFileACL(info.RelativeName)),
+		Bucket:   aws.String(p.bucket),
+		Key:      aws.String(key),
+		Metadata: metadata,
+	})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Unable to create multipart upload")
+	}
+
+	if err := p.setMultipartState(stateKey, multipartState{
+		UploadID:     *out.UploadId,
+		PartSize:     partSize,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+	}); err != nil {
+		return "", nil, errors.Wrap(err, "Unable to store upload state")
+	}
+
+	return *out.UploadId, map[int64]*s3.CompletedPart{}, nil
+}
+
+func (p *Provider) putMultipart(f providers.File) error {
+	var (
+		info     = f.Info()
+		key      = *p.relativeNameToKey(info.RelativeName)
+		partSize = p.partSize
+	)
+
+	if int64(info.Size) > partSize*maxParts {
+		// S3 limits the number of parts: Increase part size for huge files
+		partSize = (int64(info.Size) + maxParts - 1) / maxParts
+	}
+
+	// Metadata must be known when creating the upload so the content
+	// checksum needs to be calculated in an additional pass
+	contentMD5, err := f.Checksum(md5.New()) // #nosec G401 - MD5 is used as content checksum, not for security
+	if err != nil {
+		return errors.Wrap(err, "Unable to calculate content checksum")
+	}
+
+	uploadID, done, err := p.resumeOrCreateUpload(key, info, partSize, objectMetadata(info, contentMD5))
+	if err != nil {
+		return err
+	}
+
+	body, err := f.Content()
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file reader")
+	}
+	defer body.Close()
+
+	var (
+		parts              []*s3.CompletedPart
+		readErr, uploadErr error
+
+		lock = new(sync.Mutex)
+		sem  = make(chan struct{}, p.concurrency)
+		wg   = new(sync.WaitGroup)
+	)
+
+	for partNo := int64(1); ; partNo++ {
+		lock.Lock()
+		failed := uploadErr != nil
+		lock.Unlock()
+		if failed {
+			break
+		}
+
+		if part, ok := done[partNo]; ok {
+			// Uploaded in a previous run: Skip its content
+			n, err := io.CopyN(ioutil.Discard, body, partSize)
+			if err != nil && err != io.EOF {
+				readErr = errors.Wrap(err, "Unable to read source file")
+				break
+			}
+			if n == 0 {
+				break
+			}
+
+			lock.Lock()
+			parts = append(parts, part)
+			lock.Unlock()
+			continue
+		}
+
+		sem <- struct{}{}
+		buf := make([]byte, partSize)
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF {
+			<-sem
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			<-sem
+			readErr = errors.Wrap(err, "Unable to read source file")
+			break
+		}
+
+		wg.Add(1)
+		go func(partNo int64, data []byte) {
+			defer func() { <-sem; wg.Done() }()
+
+			out, err := p.s3.UploadPart(&s3.UploadPartInput{
+				Body:       bytes.NewReader(data),
+				Bucket:     aws.String(p.bucket),
+				Key:        aws.String(key),
+				PartNumber: aws.Int64(partNo),
+				UploadId:   aws.String(uploadID),
+			})
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				if uploadErr == nil {
+					uploadErr = errors.Wrapf(err, "Unable to upload part %d", partNo)
+				}
+				return
+			}
+			parts = append(parts, &s3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(partNo)})
+		}(partNo, buf[:n])
+
+		if int64(n) < partSize {
+			break
+		}
+	}
+
+	// Parts in flight must not outlive the upload
+	wg.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if uploadErr != nil {
+		// Upload state is kept to resume the upload in the next run
+		return uploadErr
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+
+	if _, err = p.s3.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(p.bucket),
+		Key:             aws.String(key),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		UploadId:        aws.String(uploadID),
+	}); err != nil {
+		return errors.Wrap(err, "Unable to complete multipart upload")
+	}
+
+	return errors.Wrap(p.deleteMultipartState(multipartStatePrefix+key), "Unable to delete upload state")
+}
@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPutMultipartResumesUpload(t *testing.T) {
+	p, fake := newTestProvider(t, "")
+	store := newMemoryStateStore(nil)
+	if err := p.SetStateStore(store); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	// Parts of 4 bytes, the fake does not enforce the minimum part size
+	p.partSize = 4
+	p.concurrency = 1
+
+	content := []byte("content in five parts")
+	fake.failParts = map[int]bool{3: true}
+
+	if _, err := p.PutFile(testFile{relativeName: "file.txt", content: content}); err == nil {
+		t.Fatal("Expected upload with failing part to fail")
+	}
+
+	if v, _ := store.GetState(multipartStatePrefix + "prefix/file.txt"); v == "" {
+		t.Fatal("Expected upload state to be kept for resuming")
+	}
+
+	var uploaded int
+	for _, up := range fake.uploads {
+		uploaded = len(up.parts)
+	}
+	if uploaded == 0 {
+		t.Fatal("Expected some parts to be uploaded before the failure")
+	}
+
+	fake.failParts = nil
+	var (
+		createsBefore = fake.countRequests(http.MethodPost, "uploads")
+		partsBefore   = fake.countRequests(http.MethodPut, "uploadId")
+	)
+
+	if _, err := p.PutFile(testFile{relativeName: "file.txt", content: content}); err != nil {
+		t.Fatalf("Unable to resume upload: %s", err)
+	}
+
+	if creates := fake.countRequests(http.MethodPost, "uploads") - createsBefore; creates != 0 {
+		t.Errorf("Expected upload to be resumed, %d uploads were created", creates)
+	}
+
+	expected := (len(content)+3)/4 - uploaded
+	if parts := fake.countRequests(http.MethodPut, "uploadId") - partsBefore; parts != expected {
+		t.Errorf("Expected only %d missing parts to be uploaded, got %d", expected, parts)
+	}
+
+	if obj := fake.objects["prefix/file.txt"]; obj == nil || !bytes.Equal(obj.content, content) {
+		t.Error("Resumed upload has wrong content")
+	}
+
+	if v, _ := store.GetState(multipartStatePrefix + "prefix/file.txt"); v != "" {
+		t.Error("Expected upload state to be deleted after completion")
+	}
+}
+
+func TestSetStateStoreAbortsOrphanedUploads(t *testing.T) {
+	p, fake := newTestProvider(t, "")
+
+	for id, up := range map[string]*fakeUpload{
+		"orphaned": {key: "prefix/orphaned.txt", initiated: time.Now().Add(-48 * time.Hour)},
+		"recent":   {key: "prefix/recent.txt", initiated: time.Now()},
+		"tracked":  {key: "prefix/tracked.txt", initiated: time.Now().Add(-48 * time.Hour)},
+	} {
+		up.parts = map[int][]byte{}
+		fake.uploads[id] = up
+	}
+
+	store := newMemoryStateStore(map[string]string{
+		multipartStatePrefix + "prefix/tracked.txt": `{"upload_id":"tracked"}`,
+	})
+	if err := p.SetStateStore(store); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	if _, ok := fake.uploads["orphaned"]; ok {
+		t.Error("Expected orphaned upload to be aborted")
+	}
+
+	for _, id := range []string{"recent", "tracked"} {
+		if _, ok := fake.uploads[id]; !ok {
+			t.Errorf("Expected %s upload to be kept", id)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package sftp
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type File struct {
+	info         os.FileInfo
+	relativeName string
+	fullPath     string
+
+	client *sftp.Client
+}
+
+func (f File) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: f.info.ModTime(),
+		Size:         uint64(f.info.Size()),
+	}
+}
+
+func (f File) Checksum(h hash.Hash) (string, error) {
+	fc, err := f.Content()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to get file contents")
+	}
+	defer fc.Close()
+
+	if _, err := io.Copy(h, fc); err != nil {
+		return "", errors.Wrap(err, "Unable to read file contents")
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f File) Content() (io.ReadCloser, error) {
+	fp, err := f.client.Open(f.fullPath)
+	return fp, errors.Wrap(err, "Unable to open file")
+}
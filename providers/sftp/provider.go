@@ -0,0 +1,313 @@
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	defaultKnownHosts = "~/.ssh/known_hosts"
+	defaultPort       = "22"
+	tempFilePrefix    = ".cloudbox-tmp-"
+)
+
+var defaultKeyFiles = []string{"~/.ssh/id_ed25519", "~/.ssh/id_rsa"}
+
+type Provider struct {
+	client    *sftp.Client
+	directory string
+}
+
+func New(uri string) (providers.CloudProvider, error) {
+	if !strings.HasPrefix(uri, "sftp://") {
+		return nil, providers.ErrInvalidURI
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid URI specified")
+	}
+
+	config, err := clientConfig(u)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create SSH client config")
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(u.Hostname(), port), config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to connect to SSH server")
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "Unable to start SFTP session")
+	}
+
+	directory := u.Path
+	if strings.HasPrefix(directory, "/~/") || directory == "/~" {
+		// Path relative to the login directory
+		directory = strings.TrimPrefix(strings.TrimPrefix(directory, "/~"), "/")
+	}
+	if directory == "" {
+		directory = "."
+	}
+
+	return &Provider{client: client, directory: directory}, nil
+}
+
+func clientConfig(u *url.URL) (*ssh.ClientConfig, error) {
+	username := u.User.Username()
+	if username == "" {
+		cu, err := user.Current()
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to determine current user")
+		}
+		username = cu.Username
+	}
+
+	knownHostsFile := u.Query().Get("known_hosts")
+	if knownHostsFile == "" {
+		knownHostsFile = defaultKnownHosts
+	}
+
+	knownHostsFile, err := homedir.Expand(knownHostsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to expand known_hosts path")
+	}
+
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to load known_hosts")
+	}
+
+	var auth []ssh.AuthMethod
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	keyFiles := defaultKeyFiles
+	if key := u.Query().Get("key"); key != "" {
+		keyFiles = []string{key}
+	}
+
+	var signers []ssh.Signer
+	for _, keyFile := range keyFiles {
+		signer, err := loadKeyFile(keyFile)
+		switch {
+		case err == nil:
+			signers = append(signers, signer)
+		case os.IsNotExist(errors.Cause(err)) && u.Query().Get("key") == "":
+			// Default key files are optional
+		default:
+			return nil, errors.Wrapf(err, "Unable to load key %q", keyFile)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	if pass, ok := u.User.Password(); ok {
+		auth = append(auth, ssh.Password(pass))
+	}
+
+	return &ssh.ClientConfig{
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+		User:            username,
+	}, nil
+}
+
+func loadKeyFile(keyFile string) (ssh.Signer, error) {
+	keyFile, err := homedir.Expand(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to expand key path")
+	}
+
+	raw, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read key")
+	}
+
+	signer, err := ssh.ParsePrivateKey(raw)
+	return signer, errors.Wrap(err, "Unable to parse key")
+}
+
+func (p *Provider) Capabilities() providers.Capability { return providers.CapBasic }
+func (p *Provider) Name() string                       { return "sftp" }
+func (p *Provider) GetChecksumMethod() hash.Hash       { return sha256.New() }
+
+func (p *Provider) ListFiles() ([]providers.File, error) {
+	var (
+		files  []providers.File
+		walker = p.client.Walk(p.directory)
+	)
+
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, errors.Wrap(err, "File listing failed")
+		}
+
+		info := walker.Stat()
+		if info.IsDir() || strings.HasPrefix(info.Name(), tempFilePrefix) {
+			// We behave like git: We don't care about dirs themselves
+			continue
+		}
+
+		files = append(files, File{
+			info:         info,
+			relativeName: p.relativeName(walker.Path()),
+			fullPath:     walker.Path(),
+			client:       p.client,
+		})
+	}
+
+	return files, nil
+}
+
+func (p *Provider) relativeName(fullPath string) string {
+	if p.directory == "." {
+		return fullPath
+	}
+	return strings.TrimPrefix(fullPath, strings.TrimRight(p.directory, "/")+"/")
+}
+
+func (p *Provider) DeleteFile(relativeName string) error {
+	if err := p.client.Remove(path.Join(p.directory, relativeName)); err != nil {
+		return errors.Wrap(err, "Unable to delete file")
+	}
+
+	// Remove directories left empty, stop at the first non-empty one
+	for dir := path.Dir(relativeName); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		entries, err := p.client.ReadDir(path.Join(p.directory, dir))
+		switch {
+		case os.IsNotExist(err):
+			// Already pruned by a concurrent delete
+			return nil
+		case err != nil:
+			return errors.Wrap(err, "Unable to read parent directory")
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err := p.client.RemoveDirectory(path.Join(p.directory, dir)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Unable to remove empty parent directory")
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) GetFile(relativeName string) (providers.File, error) {
+	fullPath := path.Join(p.directory, relativeName)
+
+	stat, err := p.client.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, providers.ErrFileNotFound
+		}
+		return nil, errors.Wrap(err, "Unable to get file stat")
+	}
+
+	if stat.IsDir() {
+		return nil, providers.ErrFileNotFound
+	}
+
+	return File{
+		info:         stat,
+		relativeName: relativeName,
+		fullPath:     fullPath,
+		client:       p.client,
+	}, nil
+}
+
+func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	var (
+		info     = f.Info()
+		fullPath = path.Join(p.directory, info.RelativeName)
+		tempPath = path.Join(path.Dir(fullPath), tempFilePrefix+path.Base(fullPath))
+	)
+
+	if err := p.client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return nil, errors.Wrap(err, "Unable to create parent directories")
+	}
+
+	// Upload into a temp file to atomically replace the target afterwards
+	fp, err := p.client.Create(tempPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create temp file")
+	}
+	defer p.client.Remove(tempPath) // Noop after successful rename
+
+	rfp, err := f.Content()
+	if err != nil {
+		fp.Close()
+		return nil, errors.Wrap(err, "Unable to get source file content")
+	}
+	defer rfp.Close()
+
+	if _, err := io.Copy(fp, rfp); err != nil {
+		fp.Close()
+		return nil, errors.Wrap(err, "Unable to copy file contents")
+	}
+
+	if err := fp.Close(); err != nil {
+		return nil, errors.Wrap(err, "Unable to close remote file")
+	}
+
+	if err := p.client.Chtimes(tempPath, time.Now(), info.LastModified); err != nil {
+		return nil, errors.Wrap(err, "Unable to set last file mod time")
+	}
+
+	if err := p.client.PosixRename(tempPath, fullPath); err != nil {
+		return nil, errors.Wrap(err, "Unable to move temp file into place")
+	}
+
+	return p.GetFile(info.RelativeName)
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	return nil, providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	return "", providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Unshare(relativeName string) error {
+	return providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
+}
@@ -0,0 +1,132 @@
+package sftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+const (
+	testUser     = "cloudbox"
+	testPassword = "secret"
+)
+
+// startTestServer starts an in-process SFTP server accepting the test
+// credentials and returns its address and a known_hosts file for it
+func startTestServer(t *testing.T, dir string) (string, string) {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate host key: %s", err)
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("Unable to create host key signer: %s", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(pass) == testPassword {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, config)
+		}
+	}()
+
+	addr := listener.Addr().String()
+	knownHostsFile := path.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostSigner.PublicKey())
+	if err = ioutil.WriteFile(knownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("Unable to write known_hosts: %s", err)
+	}
+
+	return addr, knownHostsFile
+}
+
+func serveTestConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}(requests)
+
+		server, err := sftp.NewServer(channel)
+		if err != nil {
+			channel.Close()
+			return
+		}
+		go server.Serve()
+	}
+}
+
+func TestProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudbox-sftp")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr, knownHostsFile := startTestServer(t, dir)
+
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		root, err := ioutil.TempDir(dir, "root")
+		if err != nil {
+			t.Fatalf("Unable to create root dir: %s", err)
+		}
+
+		p, err := New((&url.URL{
+			Scheme:   "sftp",
+			User:     url.UserPassword(testUser, testPassword),
+			Host:     addr,
+			Path:     root,
+			RawQuery: url.Values{"known_hosts": {knownHostsFile}}.Encode(),
+		}).String())
+		if err != nil {
+			t.Fatalf("Unable to create provider: %s", err)
+		}
+
+		return p
+	})
+}
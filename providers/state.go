@@ -0,0 +1,62 @@
+package providers
+
+import "time"
+
+// StateStore persists provider specific key-value pairs between runs
+type StateStore interface {
+	DeleteState(key string) error
+	GetState(key string) (string, error)
+	ListStateKeys() ([]string, error)
+	SetState(key, value string) error
+}
+
+// StatefulProvider is implemented by providers needing to keep
+// information (i.e. in-progress uploads) between runs. The sync
+// engine hands over a store before the first sync run.
+type StatefulProvider interface {
+	SetStateStore(StateStore) error
+}
+
+// RootIdentifier is implemented by providers able to identify the
+// storage backing their root (i.e. the device of a local directory).
+// The sync engine refuses to sync if the root vanished or changed
+// between runs as it would see every file as deleted.
+type RootIdentifier interface {
+	RootID() (string, error)
+}
+
+// Cleaner is implemented by providers leaving temporary data behind
+// when interrupted (i.e. partial downloads). The sync engine calls it
+// once before syncing, it must not be called when only reading.
+type Cleaner interface {
+	Cleanup() error
+}
+
+// Purger is implemented by providers keeping deleted files for a
+// retention period (i.e. in a trash). The sync engine calls it during
+// every sync run so providers should limit how often they do work.
+type Purger interface {
+	Purge() error
+}
+
+// Pruner is implemented by providers keeping directories which are not
+// removed when moving files out of them. The sync engine calls it for
+// every moved file after all transfers finished as pruning while files
+// are put could remove directories a transfer is about to write into.
+type Pruner interface {
+	PruneEmptyParents(relativeName string) error
+}
+
+// ShareModeChecker is implemented by providers advertising CapShare
+// without supporting every share mode (i.e. only presigned links).
+// Commands check it to refuse sharing before anything is done.
+type ShareModeChecker interface {
+	SupportsShareMode(mode ShareMode) bool
+}
+
+// ShareExpiryReporter is implemented by providers not able to expire
+// presigned shares at an exact time (i.e. only on a date). The share
+// registry stores the expiry reported for the requested one.
+type ShareExpiryReporter interface {
+	ShareExpiry(requested time.Time) time.Time
+}
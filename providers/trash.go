@@ -0,0 +1,37 @@
+package providers
+
+import (
+	"strings"
+	"time"
+)
+
+// TrashPurgeInterval is the minimum time between two purges of a trash
+const TrashPurgeInterval = time.Hour
+
+const (
+	// trashTimeFormat is appended to trashed files, always in UTC
+	trashTimeFormat = "20060102T150405.000000000"
+	trashSeparator  = "~"
+)
+
+// TrashName returns the name a file trashed at the given time is kept
+// under in the trash
+func TrashName(name string, trashedAt time.Time) string {
+	return name + trashSeparator + trashedAt.UTC().Format(trashTimeFormat)
+}
+
+// ParseTrashName splits a name created by TrashName into the original
+// name and the time the file was trashed
+func ParseTrashName(name string) (string, time.Time, bool) {
+	idx := strings.LastIndex(name, trashSeparator)
+	if idx < 0 {
+		return "", time.Time{}, false
+	}
+
+	trashedAt, err := time.Parse(trashTimeFormat, name[idx+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return name[:idx], trashedAt, true
+}
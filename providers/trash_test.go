@@ -0,0 +1,32 @@
+package providers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrashNameRoundTrip(t *testing.T) {
+	trashedAt := time.Date(2019, 7, 1, 12, 30, 45, 123456789, time.FixedZone("CEST", 2*3600))
+
+	name := TrashName("dir/file~with~tilde.txt", trashedAt)
+	if name != "dir/file~with~tilde.txt~20190701T103045.123456789" {
+		t.Errorf("Unexpected trash name %q", name)
+	}
+
+	relativeName, parsedAt, ok := ParseTrashName(name)
+	if !ok {
+		t.Fatalf("Unable to parse trash name %q", name)
+	}
+
+	if relativeName != "dir/file~with~tilde.txt" || !parsedAt.Equal(trashedAt) {
+		t.Errorf("Expected original name and time, got %q and %s", relativeName, parsedAt)
+	}
+}
+
+func TestParseTrashNameRejectsOtherNames(t *testing.T) {
+	for _, name := range []string{"file.txt", "file~backup.txt", "file.txt~20190701"} {
+		if _, _, ok := ParseTrashName(name); ok {
+			t.Errorf("Expected %q not to be parsed as trash name", name)
+		}
+	}
+}
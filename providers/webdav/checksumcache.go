@@ -0,0 +1,117 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const checksumCacheStatePrefix = "md5:"
+
+// checksumCacheEntry keeps the checksum calculated for a file the server
+// has no ownCloud checksum for (i.e. uploaded through the web interface),
+// it stays valid as long as ETag, size and modification time don't change
+type checksumCacheEntry struct {
+	ETag         string    `json:"etag"`
+	Size         uint64    `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Checksum     string    `json:"checksum"`
+}
+
+// SetStateStore enables caching calculated checksums across runs
+func (p *Provider) SetStateStore(store providers.StateStore) error {
+	p.state = store
+	return nil
+}
+
+// fillChecksum calculates the checksum of files without ownCloud
+// checksum as CapAutoChecksum promises every file info carries one. The
+// result is cached to only download the file once.
+func (p *Provider) fillChecksum(f *File, etag string) error {
+	if f.checksum != "" || !p.Capabilities().Has(providers.CapAutoChecksum) {
+		return nil
+	}
+
+	if cached, err := p.getChecksumCache(*f, etag); err != nil || cached != "" {
+		f.checksum = cached
+		return err
+	}
+
+	cs, err := f.Checksum(md5.New())
+	if err != nil {
+		return errors.Wrap(err, "Unable to calculate checksum")
+	}
+	f.checksum = cs
+
+	return p.setChecksumCache(*f, etag)
+}
+
+func (p *Provider) getChecksumCache(f File, etag string) (string, error) {
+	if p.state == nil {
+		return "", nil
+	}
+
+	raw, err := p.state.GetState(checksumCacheStatePrefix + f.relativeName)
+	if err != nil || raw == "" {
+		return "", errors.Wrap(err, "Unable to read checksum cache")
+	}
+
+	entry := checksumCacheEntry{}
+	if err = json.Unmarshal([]byte(raw), &entry); err != nil {
+		// Broken entries are replaced by a fresh calculation
+		return "", nil
+	}
+
+	if entry.ETag != etag || entry.Size != f.size || !entry.LastModified.Equal(f.lastModified) {
+		return "", nil
+	}
+
+	return entry.Checksum, nil
+}
+
+func (p *Provider) setChecksumCache(f File, etag string) error {
+	if p.state == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(checksumCacheEntry{
+		ETag:         etag,
+		Size:         f.size,
+		LastModified: f.lastModified,
+		Checksum:     f.checksum,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Unable to encode checksum cache")
+	}
+
+	return errors.Wrap(p.state.SetState(checksumCacheStatePrefix+f.relativeName, string(raw)), "Unable to store checksum cache")
+}
+
+// pruneChecksumCache removes cached checksums of files no longer listed
+func (p *Provider) pruneChecksumCache(listed map[string]bool) error {
+	if p.state == nil {
+		return nil
+	}
+
+	keys, err := p.state.ListStateKeys()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list checksum cache")
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, checksumCacheStatePrefix) || listed[strings.TrimPrefix(key, checksumCacheStatePrefix)] {
+			continue
+		}
+
+		if err := p.state.DeleteState(key); err != nil {
+			return errors.Wrap(err, "Unable to delete checksum cache")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStateStore is a StateStore keeping the state in memory
+type memoryStateStore struct {
+	lock  sync.Mutex
+	state map[string]string
+}
+
+func (m *memoryStateStore) DeleteState(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.state, key)
+	return nil
+}
+
+func (m *memoryStateStore) GetState(key string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.state[key], nil
+}
+
+func (m *memoryStateStore) ListStateKeys() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var keys []string
+	for key := range m.state {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *memoryStateStore) SetState(key, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.state[key] = value
+	return nil
+}
+
+func TestListFilesCachesCalculatedChecksums(t *testing.T) {
+	p, oc, dir := newOwnCloudProvider(t)
+	store := &memoryStateStore{state: map[string]string{}}
+	if err := p.SetStateStore(store); err != nil {
+		t.Fatalf("Unable to set state store: %s", err)
+	}
+
+	writeFile := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(path.Join(dir, "file.txt"), []byte(content), 0600); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		if err := os.Chtimes(path.Join(dir, "file.txt"), mtime, mtime); err != nil {
+			t.Fatalf("Unable to set mtime: %s", err)
+		}
+	}
+
+	listChecksum := func() string {
+		files, err := p.ListFiles()
+		if err != nil {
+			t.Fatalf("Unable to list files: %s", err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("Expected one file, got %d", len(files))
+		}
+		return files[0].Info().Checksum
+	}
+
+	writeFile("content", time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC))
+	for i := 0; i < 2; i++ {
+		if cs, expected := listChecksum(), fmt.Sprintf("%x", md5.Sum([]byte("content"))); cs != expected {
+			t.Fatalf("Expected checksum %q, got %q", expected, cs)
+		}
+	}
+
+	if oc.downloads != 1 {
+		t.Errorf("Expected file to be downloaded once, got %d downloads", oc.downloads)
+	}
+
+	writeFile("changed", time.Date(2019, 7, 1, 13, 0, 0, 0, time.UTC))
+	if cs, expected := listChecksum(), fmt.Sprintf("%x", md5.Sum([]byte("changed"))); cs != expected {
+		t.Errorf("Expected checksum of changed file %q, got %q", expected, cs)
+	}
+
+	if oc.downloads != 2 {
+		t.Errorf("Expected changed file to be downloaded again, got %d downloads", oc.downloads)
+	}
+
+	if err := os.Remove(path.Join(dir, "file.txt")); err != nil {
+		t.Fatalf("Unable to remove file: %s", err)
+	}
+	if _, err := p.ListFiles(); err != nil {
+		t.Fatalf("Unable to list files: %s", err)
+	}
+
+	if keys, _ := store.ListStateKeys(); len(keys) != 0 {
+		t.Errorf("Expected cache of removed file to be pruned, got %v", keys)
+	}
+}
@@ -0,0 +1,64 @@
+package webdav
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type File struct {
+	relativeName string
+	lastModified time.Time
+	checksum     string
+	size         uint64
+
+	provider *Provider
+}
+
+func (f File) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: f.lastModified,
+		Checksum:     f.checksum,
+		Size:         f.size,
+	}
+}
+
+func (f File) Checksum(h hash.Hash) (string, error) {
+	cont, err := f.Content()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to get file content")
+	}
+	defer cont.Close()
+
+	if _, err := io.Copy(h, cont); err != nil {
+		return "", errors.Wrap(err, "Unable to read file content")
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f File) Content() (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, f.provider.fileURL(f.relativeName).String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create request")
+	}
+
+	resp, err := f.provider.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("Unexpected status %d on GET", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,165 @@
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+	<d:prop>
+		<d:getcontentlength/>
+		<d:getetag/>
+		<d:getlastmodified/>
+		<d:resourcetype/>
+		<oc:checksums/>
+	</d:prop>
+</d:propfind>`
+
+var errInfinityDenied = errors.New("Server denied PROPFIND with infinite depth")
+
+type multistatus struct {
+	Responses []response `xml:"DAV: response"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Status string `xml:"DAV: status"`
+	Prop   prop   `xml:"DAV: prop"`
+}
+
+type prop struct {
+	ContentLength string `xml:"DAV: getcontentlength"`
+	ETag          string `xml:"DAV: getetag"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+	ResourceType  struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	Checksums []string `xml:"http://owncloud.org/ns checksums>checksum"`
+}
+
+// entry is the parsed information about a single resource
+type entry struct {
+	path         string
+	isDir        bool
+	size         uint64
+	lastModified time.Time
+	checksum     string
+	etag         string
+}
+
+func (r response) entry() (entry, error) {
+	href, err := url.Parse(r.Href)
+	if err != nil {
+		return entry{}, errors.Wrap(err, "Invalid href in response")
+	}
+
+	e := entry{path: href.Path}
+
+	for _, ps := range r.Propstats {
+		if !strings.Contains(ps.Status, " 200 ") {
+			// Properties not found or not accessible
+			continue
+		}
+
+		e.isDir = e.isDir || ps.Prop.ResourceType.Collection != nil
+
+		if ps.Prop.ContentLength != "" {
+			if e.size, err = strconv.ParseUint(ps.Prop.ContentLength, 10, 64); err != nil {
+				return e, errors.Wrap(err, "Invalid content length")
+			}
+		}
+
+		if ps.Prop.ETag != "" {
+			e.etag = ps.Prop.ETag
+		}
+
+		if ps.Prop.LastModified != "" {
+			if e.lastModified, err = http.ParseTime(ps.Prop.LastModified); err != nil {
+				return e, errors.Wrap(err, "Invalid last modified date")
+			}
+		}
+
+		// ETags are no content checksums on most servers: Files without
+		// an ownCloud / Nextcloud checksum get their checksum calculated
+		if sum := checksumFromOC(ps.Prop.Checksums); sum != "" {
+			e.checksum = sum
+		}
+	}
+
+	if e.isDir && !strings.HasSuffix(e.path, "/") {
+		e.path += "/"
+	}
+
+	return e, nil
+}
+
+// checksumFromOC extracts the MD5 from ownCloud / Nextcloud checksums
+// in the format "SHA1:<hex> MD5:<hex> ADLER32:<hex>"
+func checksumFromOC(checksums []string) string {
+	for _, c := range checksums {
+		for _, sum := range strings.Fields(c) {
+			if strings.HasPrefix(strings.ToUpper(sum), "MD5:") {
+				return strings.ToLower(sum[4:])
+			}
+		}
+	}
+	return ""
+}
+
+func (p *Provider) propfind(target *url.URL, depth string) ([]entry, error) {
+	req, err := http.NewRequest("PROPFIND", target.String(), bytes.NewReader([]byte(propfindBody)))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", depth)
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusNotFound:
+		return nil, providers.ErrFileNotFound
+	case http.StatusBadRequest, http.StatusForbidden:
+		if depth == depthInfinity {
+			return nil, errInfinityDenied
+		}
+		fallthrough
+	default:
+		return nil, errors.Errorf("Unexpected status %d on PROPFIND", resp.StatusCode)
+	}
+
+	var ms multistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode PROPFIND response")
+	}
+
+	entries := make([]entry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		e, err := r.entry()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to parse response for %q", r.Href)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,59 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestChecksumFromOC(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		checksums []string
+		expected  string
+	}{
+		{"none", nil, ""},
+		{"md5 only", []string{"MD5:ABCDEF"}, "abcdef"},
+		{"all types", []string{"SHA1:1234 MD5:abcdef ADLER32:5678"}, "abcdef"},
+		{"lowercase type", []string{"sha1:1234 md5:abcdef"}, "abcdef"},
+		{"no md5", []string{"SHA1:1234 ADLER32:5678"}, ""},
+		{"second entry", []string{"SHA1:1234", "MD5:abcdef"}, "abcdef"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if sum := checksumFromOC(tc.checksums); sum != tc.expected {
+				t.Errorf("Expected checksum %q, got %q", tc.expected, sum)
+			}
+		})
+	}
+}
+
+func TestEntryOnlyUsesOwnCloudChecksums(t *testing.T) {
+	var ms multistatus
+	if err := xml.NewDecoder(strings.NewReader(`<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+	<d:response><d:href>/plain.txt</d:href><d:propstat><d:prop>
+		<d:getetag>"0123456789abcdef0123456789abcdef"</d:getetag>
+	</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+	<d:response><d:href>/oc.txt</d:href><d:propstat><d:prop>
+		<d:getetag>"etag"</d:getetag>
+		<oc:checksums><oc:checksum>SHA1:1234 MD5:abcdef</oc:checksum></oc:checksums>
+	</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+</d:multistatus>`)).Decode(&ms); err != nil {
+		t.Fatalf("Unable to decode response: %s", err)
+	}
+
+	for i, expected := range []string{"", "abcdef"} {
+		e, err := ms.Responses[i].entry()
+		if err != nil {
+			t.Fatalf("Unable to parse entry: %s", err)
+		}
+
+		if e.checksum != expected {
+			t.Errorf("Expected checksum %q for %s, got %q", expected, e.path, e.checksum)
+		}
+
+		if e.etag == "" {
+			t.Errorf("Expected ETag to be parsed for %s", e.path)
+		}
+	}
+}
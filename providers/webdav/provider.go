@@ -0,0 +1,384 @@
+package webdav
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	depthInfinity = "infinity"
+	depthOne      = "1"
+	depthZero     = "0"
+)
+
+type Provider struct {
+	client   *http.Client
+	baseURL  *url.URL
+	username string
+	password string
+	state    providers.StateStore
+
+	depthInfinity bool
+}
+
+func New(uri string) (providers.CloudProvider, error) {
+	var scheme string
+	switch {
+	case strings.HasPrefix(uri, "webdav://"):
+		scheme = "http"
+	case strings.HasPrefix(uri, "webdavs://"):
+		scheme = "https"
+	default:
+		return nil, providers.ErrInvalidURI
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid URI specified")
+	}
+
+	p := &Provider{
+		client: &http.Client{},
+		baseURL: &url.URL{
+			Scheme: scheme,
+			Host:   u.Host,
+			Path:   strings.TrimRight(u.Path, "/") + "/",
+		},
+		username:      u.User.Username(),
+		depthInfinity: u.Query().Get("depth") == depthInfinity,
+	}
+	p.password, _ = u.User.Password()
+
+	root, err := p.propfind(p.baseURL, depthZero)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to access base collection")
+	}
+	if len(root) != 1 || !root[0].isDir {
+		return nil, errors.New("Base path is not a collection")
+	}
+
+	return p, nil
+}
+
+// Capabilities reports checksums and shares only for ownCloud / Nextcloud
+// servers: Other servers do not provide content checksums
+func (p *Provider) Capabilities() providers.Capability {
+	c := providers.CapBasic
+	if _, _, ok := p.ocsPaths(); ok {
+		c |= providers.CapAutoChecksum | providers.CapShare
+	}
+	return c
+}
+
+func (p *Provider) Name() string                 { return "webdav" }
+func (p *Provider) GetChecksumMethod() hash.Hash { return md5.New() }
+
+func (p *Provider) ListFiles() ([]providers.File, error) {
+	var (
+		depth  = depthOne
+		files  []providers.File
+		listed = map[string]bool{}
+		queue  = []*url.URL{p.baseURL}
+	)
+
+	if p.depthInfinity {
+		depth = depthInfinity
+	}
+
+	// Walk the tree one level at a time unless infinite depth was
+	// requested: Large trees produce huge responses and many servers
+	// deny or silently limit infinite depth requests
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		entries, err := p.propfind(dir, depth)
+		if errors.Cause(err) == errInfinityDenied {
+			depth = depthOne
+			entries, err = p.propfind(dir, depth)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "File listing failed")
+		}
+
+		// Collections without children in the response were not
+		// descended into by the server and need to be listed separately
+		for _, e := range entries {
+			if e.isDir && e.path != dir.Path && !hasChildren(entries, e.path) {
+				queue = append(queue, &url.URL{Scheme: p.baseURL.Scheme, Host: p.baseURL.Host, Path: e.path})
+			}
+		}
+
+		dirFiles, err := p.filesFromEntries(entries)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range dirFiles {
+			listed[f.Info().RelativeName] = true
+		}
+		files = append(files, dirFiles...)
+	}
+
+	return files, errors.Wrap(p.pruneChecksumCache(listed), "Unable to prune checksum cache")
+}
+
+func hasChildren(entries []entry, dir string) bool {
+	for _, e := range entries {
+		if e.path != dir && strings.HasPrefix(e.path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) filesFromEntries(entries []entry) ([]providers.File, error) {
+	var files []providers.File
+
+	for _, e := range entries {
+		if e.isDir {
+			// We behave like git: We don't care about dirs themselves
+			continue
+		}
+
+		f, err := p.fileFromEntry(e)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+func (p *Provider) fileFromEntry(e entry) (File, error) {
+	f := File{
+		relativeName: strings.TrimPrefix(e.path, p.baseURL.Path),
+		lastModified: e.lastModified,
+		checksum:     e.checksum,
+		size:         e.size,
+
+		provider: p,
+	}
+
+	return f, errors.Wrapf(p.fillChecksum(&f, e.etag), "Unable to get checksum of %q", f.relativeName)
+}
+
+func (p *Provider) DeleteFile(relativeName string) error {
+	if err := p.delete(p.fileURL(relativeName)); err != nil {
+		return err
+	}
+
+	// Remove collections left empty, stop at the first non-empty one
+	for dir := path.Dir(relativeName); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		entries, err := p.propfind(p.fileURL(dir+"/"), depthOne)
+		switch {
+		case err == providers.ErrFileNotFound:
+			// Already pruned by a concurrent delete
+			return nil
+		case err != nil:
+			return errors.Wrap(err, "Unable to read parent collection")
+		}
+
+		if len(entries) > 1 {
+			return nil
+		}
+
+		if err := p.delete(p.fileURL(dir + "/")); err != nil && err != providers.ErrFileNotFound {
+			return errors.Wrap(err, "Unable to remove empty parent collection")
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) delete(target *url.URL) error {
+	req, err := http.NewRequest(http.MethodDelete, target.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create request")
+	}
+
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return providers.ErrFileNotFound
+	default:
+		return errors.Errorf("Unexpected status %d on DELETE", resp.StatusCode)
+	}
+}
+
+func (p *Provider) GetFile(relativeName string) (providers.File, error) {
+	entries, err := p.propfind(p.fileURL(relativeName), depthZero)
+	if err != nil {
+		if err == providers.ErrFileNotFound {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "Unable to get file properties")
+	}
+
+	if len(entries) != 1 || entries[0].isDir {
+		return nil, providers.ErrFileNotFound
+	}
+
+	f, err := p.fileFromEntry(entries[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (p *Provider) MoveFile(from, to string) (providers.File, error) {
+	return nil, providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) PutFile(f providers.File) (providers.File, error) {
+	info := f.Info()
+
+	if err := p.mkcolAll(path.Dir(info.RelativeName)); err != nil {
+		return nil, errors.Wrap(err, "Unable to create parent collections")
+	}
+
+	content, sum, err := spoolContent(f)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		content.Close()
+		os.Remove(content.Name())
+	}()
+
+	req, err := http.NewRequest(http.MethodPut, p.fileURL(info.RelativeName).String(), content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create request")
+	}
+	if stat, err := content.Stat(); err == nil {
+		req.ContentLength = stat.Size()
+	}
+	req.Header.Set("OC-Checksum", "MD5:"+sum)
+	req.Header.Set("X-OC-Mtime", strconv.FormatInt(info.LastModified.Unix(), 10))
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	default:
+		return nil, errors.Errorf("Unexpected status %d on PUT", resp.StatusCode)
+	}
+
+	return p.GetFile(info.RelativeName)
+}
+
+// spoolContent copies the source into a temp file while hashing it: The
+// checksum is passed for ownCloud / Nextcloud to store it along with the
+// file and needs to be known before the upload starts. Reading the source
+// only once ensures checksum and uploaded content match.
+func spoolContent(f providers.File) (*os.File, string, error) {
+	src, err := f.Content()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "Unable to get source file content")
+	}
+	defer src.Close()
+
+	tmp, err := ioutil.TempFile("", "cloudbox-webdav-")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "Unable to create temp file")
+	}
+
+	h := md5.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, h), src); err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, "", errors.Wrap(err, "Unable to spool source file")
+	}
+
+	return tmp, fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (p *Provider) mkcolAll(dir string) error {
+	if dir == "." || dir == "/" {
+		return nil
+	}
+
+	var current string
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+
+		req, err := http.NewRequest("MKCOL", p.fileURL(current+"/").String(), nil)
+		if err != nil {
+			return errors.Wrap(err, "Unable to create request")
+		}
+
+		resp, err := p.do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusMethodNotAllowed:
+			// 405 is returned when the collection already exists
+		default:
+			return errors.Errorf("Unexpected status %d on MKCOL for %q", resp.StatusCode, current)
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) Watch(ctx context.Context, changes chan<- string) error {
+	return providers.ErrFeatureNotSupported
+}
+
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to execute %s request", req.Method)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, errors.New("Authentication failed")
+	}
+
+	return resp, nil
+}
+
+func (p *Provider) fileURL(relativeName string) *url.URL {
+	u := *p.baseURL
+	u.Path = p.baseURL.Path + strings.TrimLeft(relativeName, "/")
+	return &u
+}
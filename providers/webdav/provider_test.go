@@ -0,0 +1,231 @@
+package webdav
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/providerstest"
+)
+
+// mtimeHandler applies the X-OC-Mtime header after successful uploads
+// like ownCloud / Nextcloud do, the plain WebDAV handler ignores it
+type mtimeHandler struct {
+	dir    string
+	prefix string
+	next   http.Handler
+}
+
+func (h mtimeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.next.ServeHTTP(w, r)
+
+	if r.Method != http.MethodPut || r.Header.Get("X-OC-Mtime") == "" {
+		return
+	}
+
+	mtime, err := strconv.ParseInt(r.Header.Get("X-OC-Mtime"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t := time.Unix(mtime, 0)
+	os.Chtimes(path.Join(h.dir, strings.TrimPrefix(r.URL.Path, h.prefix)), t, t)
+}
+
+// startTestServer serves the given directory through an in-process
+// WebDAV server and returns its URL
+func startTestServer(t *testing.T, dir string) *url.URL {
+	srv := httptest.NewServer(mtimeHandler{
+		dir: dir,
+		next: &webdav.Handler{
+			FileSystem: webdav.Dir(dir),
+			LockSystem: webdav.NewMemLS(),
+		},
+	})
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("Unable to parse server URL: %s", err)
+	}
+
+	return u
+}
+
+func TestProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloudbox-webdav")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := startTestServer(t, dir)
+
+	providerstest.Run(t, func(t *testing.T) providers.CloudProvider {
+		root, err := ioutil.TempDir(dir, "root")
+		if err != nil {
+			t.Fatalf("Unable to create root dir: %s", err)
+		}
+
+		p, err := New("webdav://" + srv.Host + "/" + path.Base(root) + "/")
+		if err != nil {
+			t.Fatalf("Unable to create provider: %s", err)
+		}
+
+		return p
+	})
+}
+
+// ownCloudServer serves a directory like ownCloud / Nextcloud below
+// remote.php/webdav without providing checksums and records requests
+// to the OCS shares endpoint
+type ownCloudServer struct {
+	lock       sync.Mutex
+	downloads  int
+	ocChecksum string
+	shares     []url.Values
+	deleted    []string
+
+	// listed is returned when listing shares of a file
+	listed string
+}
+
+func (o *ownCloudServer) ServeOCS(w http.ResponseWriter, r *http.Request) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	var data string
+	switch r.Method {
+	case http.MethodPost:
+		r.ParseForm()
+		o.shares = append(o.shares, r.PostForm)
+		data = `{"id":"1","share_type":3,"url":"https://cloud.example.com/s/abc"}`
+	case http.MethodGet:
+		data = o.listed
+	case http.MethodDelete:
+		o.deleted = append(o.deleted, path.Base(r.URL.Path))
+		data = `[]`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ocs":{"meta":{"status":"ok","statuscode":200},"data":%s}}`, data)
+}
+
+// startOwnCloudServer serves the given directory like ownCloud and
+// returns the URI of the WebDAV root for the provider
+func startOwnCloudServer(t *testing.T, dir string) (string, *ownCloudServer) {
+	var (
+		oc  = &ownCloudServer{listed: `[]`}
+		dav = mtimeHandler{
+			dir:    dir,
+			prefix: "/remote.php/webdav",
+			next: &webdav.Handler{
+				FileSystem: webdav.Dir(dir),
+				LockSystem: webdav.NewMemLS(),
+				Prefix:     "/remote.php/webdav",
+			},
+		}
+		mux = http.NewServeMux()
+	)
+
+	mux.HandleFunc("/remote.php/webdav/", func(w http.ResponseWriter, r *http.Request) {
+		oc.lock.Lock()
+		switch r.Method {
+		case http.MethodGet:
+			oc.downloads++
+		case http.MethodPut:
+			oc.ocChecksum = r.Header.Get("OC-Checksum")
+		}
+		oc.lock.Unlock()
+
+		dav.ServeHTTP(w, r)
+	})
+	mux.HandleFunc(ocsSharesPath, oc.ServeOCS)
+	mux.HandleFunc(ocsSharesPath+"/", oc.ServeOCS)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return "webdav://" + strings.TrimPrefix(srv.URL, "http://") + "/remote.php/webdav/", oc
+}
+
+// newOwnCloudProvider creates a provider for an ownCloud server
+// serving a fresh temp dir
+func newOwnCloudProvider(t *testing.T) (*Provider, *ownCloudServer, string) {
+	dir, err := ioutil.TempDir("", "cloudbox-webdav")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	uri, oc := startOwnCloudServer(t, dir)
+
+	p, err := New(uri)
+	if err != nil {
+		t.Fatalf("Unable to create provider: %s", err)
+	}
+
+	return p.(*Provider), oc, dir
+}
+
+// countingFile counts how often its content is read
+type countingFile struct {
+	content []byte
+	reads   int
+}
+
+func (f *countingFile) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: "file.txt",
+		LastModified: time.Date(2019, 7, 1, 12, 30, 45, 0, time.UTC),
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f *countingFile) Checksum(h hash.Hash) (string, error) {
+	f.reads++
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f *countingFile) Content() (io.ReadCloser, error) {
+	f.reads++
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func TestPutFileReadsSourceOnce(t *testing.T) {
+	p, oc, dir := newOwnCloudProvider(t)
+
+	src := &countingFile{content: []byte("content")}
+	if _, err := p.PutFile(src); err != nil {
+		t.Fatalf("Unable to put file: %s", err)
+	}
+
+	if src.reads != 1 {
+		t.Errorf("Expected source to be read once, got %d reads", src.reads)
+	}
+
+	if expected := fmt.Sprintf("MD5:%x", md5.Sum(src.content)); oc.ocChecksum != expected {
+		t.Errorf("Expected OC-Checksum %q, got %q", expected, oc.ocChecksum)
+	}
+
+	if content, err := ioutil.ReadFile(path.Join(dir, "file.txt")); err != nil || string(content) != "content" {
+		t.Errorf("Expected uploaded content, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,193 @@
+package webdav
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const (
+	ocsSharesPath      = "/ocs/v2.php/apps/files_sharing/api/v1/shares"
+	ocsShareTypeLink   = 3
+	ocsPermissionsRead = "1"
+)
+
+type ocsResponse struct {
+	OCS struct {
+		Meta struct {
+			Status     string `json:"status"`
+			StatusCode int    `json:"statuscode"`
+			Message    string `json:"message"`
+		} `json:"meta"`
+		Data json.RawMessage `json:"data"`
+	} `json:"ocs"`
+}
+
+type ocsShare struct {
+	ID        json.Number `json:"id"`
+	ShareType int         `json:"share_type"`
+	URL       string      `json:"url"`
+}
+
+// ocsPaths derives the Nextcloud / ownCloud OCS shares endpoint and the
+// path of the sync root relative to the users files from the WebDAV URL
+func (p *Provider) ocsPaths() (*url.URL, string, bool) {
+	idx := strings.Index(p.baseURL.Path, "/remote.php/")
+	if idx < 0 {
+		return nil, "", false
+	}
+
+	var (
+		rest     = p.baseURL.Path[idx+len("/remote.php/"):]
+		filePath string
+	)
+
+	switch {
+	case strings.HasPrefix(rest, "webdav/"):
+		filePath = strings.TrimPrefix(rest, "webdav")
+
+	case strings.HasPrefix(rest, "dav/files/"):
+		parts := strings.SplitN(strings.TrimPrefix(rest, "dav/files/"), "/", 2)
+		if len(parts) != 2 {
+			return nil, "", false
+		}
+		filePath = "/" + parts[1]
+
+	default:
+		return nil, "", false
+	}
+
+	return &url.URL{
+		Scheme: p.baseURL.Scheme,
+		Host:   p.baseURL.Host,
+		Path:   p.baseURL.Path[:idx] + ocsSharesPath,
+	}, filePath, true
+}
+
+// ShareExpiry reports the start of the date the expiry ends on as the
+// server expires link shares on a date, not at an exact time
+func (p *Provider) ShareExpiry(requested time.Time) time.Time {
+	y, m, d := requested.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, requested.Location())
+}
+
+// Share creates a public link share. Presigned shares are links with an
+// expiry date, see ShareExpiry.
+func (p *Provider) Share(relativeName string, opts providers.ShareOptions) (string, error) {
+	endpoint, filePath, ok := p.ocsPaths()
+	if !ok {
+		return "", providers.ErrFeatureNotSupported
+	}
+
+	form := url.Values{
+		"path":        []string{path.Join(filePath, relativeName)},
+		"permissions": []string{ocsPermissionsRead},
+		"shareType":   []string{strconv.Itoa(ocsShareTypeLink)},
+	}
+
+	switch opts.Mode {
+	case providers.ShareModePresigned:
+		if opts.Expires <= 0 {
+			return "", errors.New("Expiry must be greater than 0")
+		}
+		form.Set("expireDate", p.ShareExpiry(time.Now().Add(opts.Expires)).Format("2006-01-02"))
+	case "", providers.ShareModePublic:
+	default:
+		return "", providers.ErrFeatureNotSupported
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to create request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var share ocsShare
+	if err := p.ocsRequest(req, &share); err != nil {
+		return "", errors.Wrap(err, "Unable to create share")
+	}
+
+	return share.URL, nil
+}
+
+// Unshare removes all link shares of the given file
+func (p *Provider) Unshare(relativeName string) error {
+	endpoint, filePath, ok := p.ocsPaths()
+	if !ok {
+		return providers.ErrFeatureNotSupported
+	}
+
+	listURL := *endpoint
+	listURL.RawQuery = url.Values{
+		"path":     []string{path.Join(filePath, relativeName)},
+		"reshares": []string{"false"},
+	}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, listURL.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create request")
+	}
+
+	var shares []ocsShare
+	if err := p.ocsRequest(req, &shares); err != nil {
+		return errors.Wrap(err, "Unable to list shares")
+	}
+
+	for _, share := range shares {
+		if share.ShareType != ocsShareTypeLink {
+			continue
+		}
+
+		deleteURL := *endpoint
+		deleteURL.Path = path.Join(endpoint.Path, share.ID.String())
+
+		req, err := http.NewRequest(http.MethodDelete, deleteURL.String(), nil)
+		if err != nil {
+			return errors.Wrap(err, "Unable to create request")
+		}
+
+		if err := p.ocsRequest(req, nil); err != nil {
+			return errors.Wrapf(err, "Unable to delete share %s", share.ID)
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) ocsRequest(req *http.Request, data interface{}) error {
+	q := req.URL.Query()
+	q.Set("format", "json")
+	req.URL.RawQuery = q.Encode()
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("OCS-APIRequest", "true")
+
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r ocsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return errors.Wrapf(err, "Unable to decode OCS response (status %d)", resp.StatusCode)
+	}
+
+	if r.OCS.Meta.Status != "ok" {
+		return errors.Errorf("OCS request failed: %s (%d)", r.OCS.Meta.Message, r.OCS.Meta.StatusCode)
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal(r.OCS.Data, data), "Unable to decode OCS data")
+}
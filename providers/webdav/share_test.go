@@ -0,0 +1,78 @@
+package webdav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+func TestCapabilitiesOnlyForOwnCloud(t *testing.T) {
+	p, _, _ := newOwnCloudProvider(t)
+	if c := p.Capabilities(); !c.Has(providers.CapAutoChecksum) || !c.Has(providers.CapShare) {
+		t.Errorf("Expected checksums and shares for ownCloud servers, got %b", c)
+	}
+
+	plainURL := *p.baseURL
+	plainURL.Path = "/files/"
+	if c := (&Provider{baseURL: &plainURL}).Capabilities(); c.Has(providers.CapAutoChecksum) || c.Has(providers.CapShare) {
+		t.Errorf("Expected no checksums and shares for other servers, got %b", c)
+	}
+}
+
+func TestShareCreatesLinkShares(t *testing.T) {
+	p, oc, _ := newOwnCloudProvider(t)
+
+	shareURL, err := p.Share("dir/file.txt", providers.ShareOptions{Mode: providers.ShareModePublic})
+	if err != nil {
+		t.Fatalf("Unable to share file: %s", err)
+	}
+
+	if shareURL != "https://cloud.example.com/s/abc" {
+		t.Errorf("Unexpected share URL %q", shareURL)
+	}
+
+	if _, err = p.Share("dir/file.txt", providers.ShareOptions{Mode: providers.ShareModePresigned, Expires: 48 * time.Hour}); err != nil {
+		t.Fatalf("Unable to share file: %s", err)
+	}
+
+	if len(oc.shares) != 2 {
+		t.Fatalf("Expected 2 shares to be created, got %d", len(oc.shares))
+	}
+
+	for _, form := range oc.shares {
+		if form.Get("path") != "/dir/file.txt" || form.Get("shareType") != "3" || form.Get("permissions") != "1" {
+			t.Errorf("Expected read-only link share of /dir/file.txt, got %v", form)
+		}
+	}
+
+	if expireDate := oc.shares[0].Get("expireDate"); expireDate != "" {
+		t.Errorf("Expected public share not to expire, got %q", expireDate)
+	}
+
+	if expected := time.Now().Add(48 * time.Hour).Format("2006-01-02"); oc.shares[1].Get("expireDate") != expected {
+		t.Errorf("Expected presigned share to expire on %s, got %q", expected, oc.shares[1].Get("expireDate"))
+	}
+}
+
+func TestShareExpiryIsStartOfExpiryDate(t *testing.T) {
+	p, _, _ := newOwnCloudProvider(t)
+
+	requested := time.Date(2019, 7, 1, 17, 30, 0, 0, time.Local)
+	if expiry := p.ShareExpiry(requested); !expiry.Equal(time.Date(2019, 7, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected expiry at the start of the date, got %s", expiry)
+	}
+}
+
+func TestUnshareDeletesOnlyLinkShares(t *testing.T) {
+	p, oc, _ := newOwnCloudProvider(t)
+	oc.listed = `[{"id":"7","share_type":0},{"id":"8","share_type":3},{"id":"9","share_type":3}]`
+
+	if err := p.Unshare("file.txt"); err != nil {
+		t.Fatalf("Unable to unshare file: %s", err)
+	}
+
+	if len(oc.deleted) != 2 || oc.deleted[0] != "8" || oc.deleted[1] != "9" {
+		t.Errorf("Expected link shares 8 and 9 to be deleted, got %v", oc.deleted)
+	}
+}
@@ -0,0 +1,102 @@
+package sync
+
+import "fmt"
+
+type Action string
+
+const (
+	ActionNone         Action = "none"
+	ActionCompare      Action = "compare"
+	ActionConflict     Action = "conflict"
+	ActionCleanState   Action = "clean-state"
+	ActionUpload       Action = "upload"
+	ActionDownload     Action = "download"
+	ActionDeleteLocal  Action = "delete-local"
+	ActionDeleteRemote Action = "delete-remote"
+	ActionMoveLocal    Action = "move-local"
+	ActionMoveRemote   Action = "move-remote"
+	ActionUnhandled    Action = "unhandled"
+)
+
+// PlannedAction describes what a sync run would do to a single file
+type PlannedAction struct {
+	RelativeName string `json:"relative_name"`
+	Action       Action `json:"action"`
+	Change       string `json:"change"`
+	MovedFrom    string `json:"moved_from,omitempty"`
+	Strategy     string `json:"strategy,omitempty"`
+}
+
+func (s *Sync) planAction(change Change) Action {
+	switch {
+	case !change.Changed():
+		// No changes at all
+		return ActionNone
+
+	case change.HasAll(ChangeLocalAdd, ChangeRemoteAdd):
+		// Special case: Both are added, check they are the same file
+		return ActionCompare
+
+	case change.HasOne(ChangeLocalAdd, ChangeLocalUpdate) && change.HasOne(ChangeRemoteAdd, ChangeRemoteUpdate):
+		// We do have local and remote changes: Resolve by configured strategy
+		return ActionConflict
+
+	case change.HasAll(ChangeLocalDelete, ChangeRemoteDelete):
+		// Special case: Both vanished, we just need to clean up the sync cache
+		return ActionCleanState
+
+	case change.HasOne(ChangeLocalAdd, ChangeLocalUpdate) && change.HasOne(ChangeRemoteDelete):
+		// Updated locally, deleted remotely
+		if s.conf.DeleteConflictStrategy == DeleteConflictDeleteWins {
+			return ActionDeleteLocal
+		}
+		return ActionUpload
+
+	case change.HasOne(ChangeLocalDelete) && change.HasOne(ChangeRemoteAdd, ChangeRemoteUpdate):
+		// Deleted locally, updated remotely
+		if s.conf.DeleteConflictStrategy == DeleteConflictDeleteWins {
+			return ActionDeleteRemote
+		}
+		return ActionDownload
+
+	case change.Is(ChangeLocalAdd) || change.Is(ChangeLocalUpdate):
+		return ActionUpload
+
+	case change.Is(ChangeLocalDelete):
+		return ActionDeleteRemote
+
+	case change.Is(ChangeRemoteAdd) || change.Is(ChangeRemoteUpdate):
+		return ActionDownload
+
+	case change.Is(ChangeRemoteDelete):
+		return ActionDeleteLocal
+
+	default:
+		// Unhandled case (i.e. human screwed around in sync process)
+		return ActionUnhandled
+	}
+}
+
+// RunSummary counts the actions executed during a sync run
+type RunSummary struct {
+	Uploaded   int `json:"uploaded"`
+	Downloaded int `json:"downloaded"`
+	Deleted    int `json:"deleted"`
+	Moved      int `json:"moved"`
+	Conflicted int `json:"conflicted"`
+	Failed     int `json:"failed"`
+}
+
+func (r *RunSummary) add(o RunSummary) {
+	r.Uploaded += o.Uploaded
+	r.Downloaded += o.Downloaded
+	r.Deleted += o.Deleted
+	r.Moved += o.Moved
+	r.Conflicted += o.Conflicted
+	r.Failed += o.Failed
+}
+
+func (r RunSummary) String() string {
+	return fmt.Sprintf("uploaded=%d downloaded=%d deleted=%d moved=%d conflicted=%d failed=%d",
+		r.Uploaded, r.Downloaded, r.Deleted, r.Moved, r.Conflicted, r.Failed)
+}
@@ -0,0 +1,91 @@
+package sync
+
+import "testing"
+
+func TestPlanAction(t *testing.T) {
+	// Combinations of add, update and delete on the same side can not be
+	// produced by the state but are covered to pin the planned action
+	for _, tc := range []struct {
+		change     Change
+		updateWins Action
+		deleteWins Action
+	}{
+		{0, ActionNone, ActionNone},
+		{ChangeLocalAdd, ActionUpload, ActionUpload},
+		{ChangeLocalDelete, ActionDeleteRemote, ActionDeleteRemote},
+		{ChangeLocalAdd | ChangeLocalDelete, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalUpdate, ActionUpload, ActionUpload},
+		{ChangeLocalAdd | ChangeLocalUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalDelete | ChangeLocalUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeRemoteAdd, ActionDownload, ActionDownload},
+		{ChangeLocalAdd | ChangeRemoteAdd, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeRemoteAdd, ActionDownload, ActionDeleteRemote},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteAdd, ActionCompare, ActionCompare},
+		{ChangeLocalUpdate | ChangeRemoteAdd, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteAdd, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd, ActionCompare, ActionCompare},
+		{ChangeRemoteDelete, ActionDeleteLocal, ActionDeleteLocal},
+		{ChangeLocalAdd | ChangeRemoteDelete, ActionUpload, ActionDeleteLocal},
+		{ChangeLocalDelete | ChangeRemoteDelete, ActionCleanState, ActionCleanState},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteDelete, ActionCleanState, ActionCleanState},
+		{ChangeLocalUpdate | ChangeRemoteDelete, ActionUpload, ActionDeleteLocal},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteDelete, ActionUpload, ActionDeleteLocal},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteDelete, ActionCleanState, ActionCleanState},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteDelete, ActionCleanState, ActionCleanState},
+		{ChangeRemoteAdd | ChangeRemoteDelete, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalAdd | ChangeRemoteAdd | ChangeRemoteDelete, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteDelete, ActionCleanState, ActionCleanState},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteDelete, ActionCompare, ActionCompare},
+		{ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete, ActionCompare, ActionCompare},
+		{ChangeRemoteUpdate, ActionDownload, ActionDownload},
+		{ChangeLocalAdd | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalDelete | ChangeRemoteUpdate, ActionDownload, ActionDeleteRemote},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalUpdate | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeRemoteAdd | ChangeRemoteUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalAdd | ChangeRemoteAdd | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteUpdate, ActionDownload, ActionDeleteRemote},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeRemoteDelete | ChangeRemoteUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalDelete | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCleanState, ActionCleanState},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalUpdate | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionUnhandled, ActionUnhandled},
+		{ChangeLocalAdd | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCleanState, ActionCleanState},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+		{ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionConflict, ActionConflict},
+		{ChangeLocalAdd | ChangeLocalDelete | ChangeLocalUpdate | ChangeRemoteAdd | ChangeRemoteDelete | ChangeRemoteUpdate, ActionCompare, ActionCompare},
+	} {
+		for strategy, expected := range map[DeleteConflictStrategy]Action{
+			"":                       tc.updateWins,
+			DeleteConflictUpdateWins: tc.updateWins,
+			DeleteConflictDeleteWins: tc.deleteWins,
+		} {
+			s := &Sync{conf: Config{DeleteConflictStrategy: strategy}}
+
+			if action := s.planAction(tc.change); action != expected {
+				t.Errorf("Change %q with delete conflict strategy %q: expected %q, got %q",
+					tc.change, strategy, expected, action)
+			}
+		}
+	}
+}
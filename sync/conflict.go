@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type ConflictStrategy string
+
+const (
+	ConflictKeepBoth   ConflictStrategy = "keep-both"
+	ConflictNewestWins ConflictStrategy = "newest-wins"
+	ConflictLocalWins  ConflictStrategy = "local-wins"
+	ConflictRemoteWins ConflictStrategy = "remote-wins"
+	ConflictManual     ConflictStrategy = "manual"
+)
+
+type DeleteConflictStrategy string
+
+const (
+	DeleteConflictUpdateWins DeleteConflictStrategy = "update-wins"
+	DeleteConflictDeleteWins DeleteConflictStrategy = "delete-wins"
+)
+
+func (d DeleteConflictStrategy) IsValid() bool {
+	switch d {
+	case "", DeleteConflictUpdateWins, DeleteConflictDeleteWins:
+		return true
+	}
+	return false
+}
+
+func (c ConflictStrategy) IsValid() bool {
+	switch c {
+	case "", ConflictKeepBoth, ConflictNewestWins, ConflictLocalWins, ConflictRemoteWins, ConflictManual:
+		return true
+	}
+	return false
+}
+
+// renamedFile wraps a file to present it under a different relative name
+type renamedFile struct {
+	providers.File
+	relativeName string
+}
+
+func (r renamedFile) Info() providers.FileInfo {
+	info := r.File.Info()
+	info.RelativeName = r.relativeName
+	return info
+}
+
+// conflictFileName returns the name of the n-th conflict copy of the
+// file created on the given day, the first one has no counter
+func conflictFileName(relativeName string, now time.Time, n int) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+
+	var (
+		ext  = path.Ext(relativeName)
+		base = strings.TrimSuffix(relativeName, ext)
+	)
+
+	suffix := fmt.Sprintf("conflict %s %s", hostname, now.Format("2006-01-02"))
+	if n > 1 {
+		suffix = fmt.Sprintf("%s %d", suffix, n)
+	}
+
+	return fmt.Sprintf("%s (%s)%s", base, suffix, ext)
+}
+
+// freeConflictFileName finds a conflict copy name not yet existing on
+// either side: Earlier conflict copies must never be overwritten
+func (s *Sync) freeConflictFileName(relativeName string, now time.Time) (string, error) {
+	for n := 1; ; n++ {
+		var (
+			name  = conflictFileName(relativeName, now, n)
+			taken bool
+		)
+
+		for _, p := range []providers.CloudProvider{s.local, s.remote} {
+			_, err := p.GetFile(name)
+			switch {
+			case err == nil:
+				taken = true
+			case err != providers.ErrFileNotFound:
+				return "", errors.Wrap(err, "Unable to check for existing conflict copy")
+			}
+		}
+
+		if !taken {
+			return name, nil
+		}
+	}
+}
+
+func (s *Sync) resolveConflict(syncState *state, fileName string) (ConflictStrategy, error) {
+	// Both sides might have received the same update
+	if err := s.addBothCreated(fileName); err == nil {
+		return "", nil
+	}
+
+	strategy := s.conf.ConflictStrategy
+	if strategy == ConflictNewestWins {
+		strategy = ConflictLocalWins
+		if local, remote := syncState.GetScanInfo(fileName); remote != nil &&
+			(local == nil || remote.LastModified.After(local.LastModified)) {
+			strategy = ConflictRemoteWins
+		}
+	}
+
+	switch strategy {
+	case ConflictKeepBoth:
+		if err := s.keepBothVersions(fileName); err != nil {
+			return strategy, errors.Wrap(err, "Unable to keep both versions")
+		}
+
+	case ConflictLocalWins:
+		if err := s.transferFile(s.local, s.remote, sideLocal, sideRemote, fileName); err != nil {
+			return strategy, errors.Wrap(err, "Unable to upload file")
+		}
+
+	case ConflictRemoteWins:
+		if err := s.transferFile(s.remote, s.local, sideRemote, sideLocal, fileName); err != nil {
+			return strategy, errors.Wrap(err, "Unable to download file")
+		}
+
+	default:
+		// Manual resolve: Leave both versions untouched
+		return ConflictManual, nil
+	}
+
+	return strategy, errors.Wrap(s.setDBConflict(fileName, strategy), "Unable to record conflict resolution")
+}
+
+// recordManualConflict stores the unresolved conflict and reports whether
+// it is new: Conflicts are warned about again only after one of the
+// versions changed since the last detection.
+func (s *Sync) recordManualConflict(syncState *state, fileName string) (bool, error) {
+	strategy, detectedAt, err := s.getDBConflict(fileName)
+	if err != nil {
+		return true, errors.Wrap(err, "Unable to read recorded conflict")
+	}
+
+	if strategy == ConflictManual {
+		local, remote := syncState.GetScanInfo(fileName)
+		if !modifiedAfter(local, detectedAt) && !modifiedAfter(remote, detectedAt) {
+			return false, nil
+		}
+	}
+
+	return true, errors.Wrap(s.setDBConflict(fileName, ConflictManual), "Unable to record conflict")
+}
+
+func modifiedAfter(info *providers.FileInfo, t time.Time) bool {
+	return info != nil && info.LastModified.After(t)
+}
+
+func (s *Sync) keepBothVersions(fileName string) error {
+	conflictName, err := s.freeConflictFileName(fileName, time.Now())
+	if err != nil {
+		return err
+	}
+
+	local, err := s.local.GetFile(fileName)
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve local file")
+	}
+
+	// Preserve the local version under the conflict name on both sides
+	if _, err = s.local.PutFile(renamedFile{File: local, relativeName: conflictName}); err != nil {
+		return errors.Wrap(err, "Unable to create local conflict copy")
+	}
+
+	if err = s.transferFile(s.local, s.remote, sideLocal, sideRemote, conflictName); err != nil {
+		return errors.Wrap(err, "Unable to upload conflict copy")
+	}
+
+	// Remote version takes over the original name
+	return errors.Wrap(s.transferFile(s.remote, s.local, sideRemote, sideLocal, fileName), "Unable to download remote version")
+}
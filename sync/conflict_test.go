@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// prepareConflict syncs a file and afterwards changes it on both sides
+func prepareConflict(t *testing.T, s *Sync, localChange, remoteChange time.Time) {
+	base := time.Now().Add(-time.Hour)
+	putTestFile(t, s.local, "file.txt", "original", base)
+	runTestSync(t, s)
+
+	putTestFile(t, s.local, "file.txt", "local", localChange)
+	putTestFile(t, s.remote, "file.txt", "remote change", remoteChange)
+}
+
+func TestConflictRecordsResolvedStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		strategy ConflictStrategy
+		expected ConflictStrategy
+	}{
+		{ConflictLocalWins, ConflictLocalWins},
+		{ConflictRemoteWins, ConflictRemoteWins},
+		{ConflictNewestWins, ConflictRemoteWins},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			s := newTestSync(t, Config{ConflictStrategy: tc.strategy})
+
+			now := time.Now()
+			prepareConflict(t, s, now.Add(-time.Minute), now)
+
+			if summary := runTestSync(t, s); summary.Conflicted != 1 {
+				t.Fatalf("Expected one conflict, got %s", summary)
+			}
+
+			strategy, _, err := s.getDBConflict("file.txt")
+			if err != nil {
+				t.Fatalf("Unable to read conflict: %s", err)
+			}
+
+			if strategy != tc.expected {
+				t.Errorf("Expected recorded strategy %q, got %q", tc.expected, strategy)
+			}
+		})
+	}
+}
+
+func TestManualConflictIsNewOnlyAfterChange(t *testing.T) {
+	s := newTestSync(t, Config{ConflictStrategy: ConflictManual})
+
+	changed := time.Now().Add(-time.Minute)
+	prepareConflict(t, s, changed, changed)
+
+	syncState, err := s.buildState()
+	if err != nil {
+		t.Fatalf("Unable to build state: %s", err)
+	}
+
+	if isNew, err := s.recordManualConflict(syncState, "file.txt"); err != nil || !isNew {
+		t.Fatalf("Expected first detection to be new: isNew=%v err=%v", isNew, err)
+	}
+
+	if strategy, _, _ := s.getDBConflict("file.txt"); strategy != ConflictManual {
+		t.Errorf("Expected manual conflict to be recorded, got %q", strategy)
+	}
+
+	if isNew, err := s.recordManualConflict(syncState, "file.txt"); err != nil || isNew {
+		t.Fatalf("Expected unchanged conflict not to be new: isNew=%v err=%v", isNew, err)
+	}
+
+	putTestFile(t, s.local, "file.txt", "another local change", time.Now().Add(time.Minute))
+	if syncState, err = s.buildState(); err != nil {
+		t.Fatalf("Unable to build state: %s", err)
+	}
+
+	if isNew, err := s.recordManualConflict(syncState, "file.txt"); err != nil || !isNew {
+		t.Fatalf("Expected changed conflict to be new: isNew=%v err=%v", isNew, err)
+	}
+}
+
+func TestKeepBothNeverOverwritesConflictCopies(t *testing.T) {
+	s := newTestSync(t, Config{ConflictStrategy: ConflictKeepBoth})
+
+	now := time.Now()
+	prepareConflict(t, s, now.Add(-time.Minute), now)
+	runTestSync(t, s)
+
+	putTestFile(t, s.local, "file.txt", "second local", now.Add(time.Minute))
+	putTestFile(t, s.remote, "file.txt", "second remote", now.Add(2*time.Minute))
+	if summary := runTestSync(t, s); summary.Conflicted != 1 {
+		t.Fatalf("Expected second conflict, got %s", summary)
+	}
+
+	for name, expected := range map[string]string{
+		conflictFileName("file.txt", now, 1): "local",
+		conflictFileName("file.txt", now, 2): "second local",
+	} {
+		for _, p := range []providers.CloudProvider{s.local, s.remote} {
+			if content := readTestFile(t, p, name); content != expected {
+				t.Errorf("Expected %q to contain %q on %s, got %q", name, expected, p.Name(), content)
+			}
+		}
+	}
+}
+
+func readTestFile(t *testing.T, p providers.CloudProvider, relativeName string) string {
+	f, err := p.GetFile(relativeName)
+	if err != nil {
+		t.Fatalf("Unable to get %q: %s", relativeName, err)
+	}
+
+	rc, err := f.Content()
+	if err != nil {
+		t.Fatalf("Unable to open %q: %s", relativeName, err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Unable to read %q: %s", relativeName, err)
+	}
+
+	return string(content)
+}
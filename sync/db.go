@@ -1,7 +1,9 @@
 package sync
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -21,14 +23,40 @@ CREATE TABLE IF NOT EXISTS remote_state (
 	checksum TEXT,
 	size INT
 );
+CREATE TABLE IF NOT EXISTS conflicts (
+	relative_name TEXT PRIMARY KEY,
+	strategy TEXT,
+	resolved_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS provider_state (
+	provider TEXT,
+	key TEXT,
+	value TEXT,
+	PRIMARY KEY (provider, key)
+);
+CREATE TABLE IF NOT EXISTS shares (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	relative_name TEXT,
+	url TEXT,
+	mode TEXT,
+	creator TEXT,
+	created_at DATETIME,
+	expires_at DATETIME
+);
 `
 
 func (s *Sync) initSchema() error {
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	return errors.Wrap(s.runMigrations(), "Unable to migrate database")
 }
 
 func (s *Sync) deleteDBFileInfo(side, relativeName string) error {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+
 	// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
 	stmt, err := s.db.Prepare(fmt.Sprintf(`DELETE FROM %s_state WHERE relative_name = ?`, side))
 	if err != nil {
@@ -39,7 +67,47 @@ func (s *Sync) deleteDBFileInfo(side, relativeName string) error {
 	return errors.Wrap(err, "Unable to delete file info")
 }
 
+// getDBConflict returns the last recorded strategy for a conflict on the
+// file and when it was recorded, an empty strategy if none is recorded
+func (s *Sync) getDBConflict(relativeName string) (ConflictStrategy, time.Time, error) {
+	var (
+		strategy   string
+		resolvedAt time.Time
+	)
+
+	err := s.db.QueryRow(`SELECT strategy, resolved_at FROM conflicts WHERE relative_name = ?`, relativeName).
+		Scan(&strategy, &resolvedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", time.Time{}, nil
+	case err != nil:
+		return "", time.Time{}, errors.Wrap(err, "Unable to query conflict")
+	}
+
+	return ConflictStrategy(strategy), resolvedAt, nil
+}
+
+func (s *Sync) setDBConflict(relativeName string, strategy ConflictStrategy) error {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+
+	stmt, err := s.db.Prepare(
+		`INSERT INTO conflicts VALUES(?, ?, ?)
+			ON CONFLICT(relative_name) DO UPDATE SET
+				strategy=excluded.strategy,
+				resolved_at=excluded.resolved_at`)
+	if err != nil {
+		return errors.Wrap(err, "Unable to prepare query")
+	}
+
+	_, err = stmt.Exec(relativeName, string(strategy), time.Now())
+	return errors.Wrap(err, "Unable to upsert conflict resolution")
+}
+
 func (s *Sync) setDBFileInfo(side string, info providers.FileInfo) error {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+
 	// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
 	stmt, err := s.db.Prepare(fmt.Sprintf(
 		`INSERT INTO %s_state VALUES(?, ?, ?, ?) 
@@ -9,9 +9,6 @@ import (
 )
 
 func (s *Sync) addBothCreated(fileName string) error {
-	// Use forced sha256 to ensure lesser chance for collision
-	var hashMethod = sha256.New()
-
 	local, err := s.local.GetFile(fileName)
 	if err != nil {
 		return errors.Wrap(err, "Unable to retrieve file from local")
@@ -22,18 +19,26 @@ func (s *Sync) addBothCreated(fileName string) error {
 		return errors.Wrap(err, "Unable to retrieve file from remote")
 	}
 
-	localSum, err := local.Checksum(hashMethod)
+	equal, err := s.compareByRemoteChecksum(local, remote)
 	if err != nil {
-		return errors.Wrap(err, "Unable to get checksum from local file")
+		return errors.Wrap(err, "Unable to compare checksums")
 	}
 
-	remoteSum, err := remote.Checksum(hashMethod)
-	if err != nil {
-		return errors.Wrap(err, "Unable to get checksum from remote file")
-	}
+	if !equal {
+		// Use forced sha256 to ensure lesser chance for collision
+		localSum, err := local.Checksum(sha256.New())
+		if err != nil {
+			return errors.Wrap(err, "Unable to get checksum from local file")
+		}
 
-	if localSum != remoteSum {
-		return errors.New("Checksums differ")
+		remoteSum, err := remote.Checksum(sha256.New())
+		if err != nil {
+			return errors.Wrap(err, "Unable to get checksum from remote file")
+		}
+
+		if localSum != remoteSum {
+			return errors.New("Checksums differ")
+		}
 	}
 
 	localInfo, err := s.getFileInfo(local)
@@ -57,6 +62,24 @@ func (s *Sync) addBothCreated(fileName string) error {
 	return nil
 }
 
+// compareByRemoteChecksum checks the local file against the checksum
+// provided by the remote without downloading the remote file. A false
+// result is not conclusive as the remote checksum might be in a format
+// not reproducible locally (i.e. S3 multipart ETags).
+func (s *Sync) compareByRemoteChecksum(local, remote providers.File) (bool, error) {
+	remoteSum := remote.Info().Checksum
+	if !s.remote.Capabilities().Has(providers.CapAutoChecksum) || remoteSum == "" {
+		return false, nil
+	}
+
+	localSum, err := local.Checksum(s.remote.GetChecksumMethod())
+	if err != nil {
+		return false, errors.Wrap(err, "Unable to get checksum from local file")
+	}
+
+	return localSum == remoteSum, nil
+}
+
 func (s *Sync) deleteFile(on providers.CloudProvider, fileName string) error {
 	if err := on.DeleteFile(fileName); err != nil {
 		return errors.Wrap(err, "Unable to delete file")
@@ -73,13 +96,50 @@ func (s *Sync) deleteFile(on providers.CloudProvider, fileName string) error {
 	return nil
 }
 
+func (s *Sync) moveFile(source, target providers.CloudProvider, sourceSide, targetSide, from, to string) error {
+	newFile, err := target.MoveFile(from, to)
+	if err != nil {
+		return errors.Wrap(err, "Unable to move file")
+	}
+
+	newFileInfo, err := s.getFileInfo(newFile)
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file info for target file")
+	}
+
+	if err := s.setDBFileInfo(targetSide, newFileInfo); err != nil {
+		return errors.Wrap(err, "Unable to update DB info for target file")
+	}
+
+	file, err := source.GetFile(to)
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve source file")
+	}
+
+	fileInfo, err := s.getFileInfo(file)
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file info for source file")
+	}
+
+	if err := s.setDBFileInfo(sourceSide, fileInfo); err != nil {
+		return errors.Wrap(err, "Unable to update DB info for source file")
+	}
+
+	if err := s.deleteDBFileInfo(sideLocal, from); err != nil {
+		return errors.Wrap(err, "Unable to delete local file info")
+	}
+
+	return errors.Wrap(s.deleteDBFileInfo(sideRemote, from), "Unable to delete remote file info")
+}
+
 func (s *Sync) transferFile(from, to providers.CloudProvider, sideFrom, sideTo, fileName string) error {
 	file, err := from.GetFile(fileName)
 	if err != nil {
 		return errors.Wrap(err, "Unable to retrieve file")
 	}
 
-	newFile, err := to.PutFile(file)
+	// Checksum in the file info is created by the source provider
+	newFile, err := to.PutFile(providers.WithChecksumMethod(file, from.GetChecksumMethod))
 	if err != nil {
 		return errors.Wrap(err, "Unable to put file")
 	}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/ignore"
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// CheckIgnore loads the current ignore rules and returns the rule
+// deciding about the given relative name or nil if none matches
+func (s *Sync) CheckIgnore(relativeName string) (*ignore.Rule, error) {
+	files, err := s.local.ListFiles()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list local files")
+	}
+
+	m, err := s.loadIgnoreRules(files)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to load ignore rules")
+	}
+
+	return m.Match(relativeName), nil
+}
+
+func (s *Sync) loadIgnoreRules(files []providers.File) (*ignore.Matcher, error) {
+	m := ignore.New(s.conf.IgnorePatterns)
+
+	for _, f := range files {
+		relativeName := f.Info().RelativeName
+		if path.Base(relativeName) != ignore.FileName {
+			continue
+		}
+
+		content, err := f.Content()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to open ignore file %q", relativeName)
+		}
+
+		err = m.AddFile(path.Dir(relativeName), content)
+		content.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to parse ignore file %q", relativeName)
+		}
+	}
+
+	return m, nil
+}
+
+func (s *Sync) syncableNames(syncState *state) []string {
+	var out []string
+	for _, fileName := range syncState.GetRelativeNames() {
+		if !s.ignore.Ignored(fileName) {
+			out = append(out, fileName)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"crypto/md5"  // #nosec G501 - Used to repair stored checksums, not for security
+	"crypto/sha1" // #nosec G505 - Used to repair stored checksums, not for security
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// migrations are executed in order, the index of the last executed
+// migration is stored in the database user_version
+var migrations = []func(tx *sql.Tx) error{
+	migrateBrokenChecksums,
+}
+
+func (s *Sync) runMigrations() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return errors.Wrap(err, "Unable to read schema version")
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return errors.Wrap(err, "Unable to start transaction")
+		}
+
+		if err = migrations[i](tx); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Migration %d failed", i+1)
+		}
+
+		// PRAGMA does not support placeholders, value is an integer
+		if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Unable to update schema version")
+		}
+
+		if err = tx.Commit(); err != nil {
+			return errors.Wrap(err, "Unable to commit migration")
+		}
+	}
+
+	return nil
+}
+
+// checkSchema verifies the database is usable for reading without
+// modifying it and reports whether it was initialized by a sync before
+func (s *Sync) checkSchema() (bool, error) {
+	var tables int
+	if err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN (?, ?)`,
+		sideLocal+"_state", sideRemote+"_state",
+	).Scan(&tables); err != nil {
+		return false, errors.Wrap(err, "Unable to read schema")
+	}
+
+	if tables == 0 {
+		// Nothing synced yet: Database is equivalent to an empty one
+		return false, nil
+	}
+
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return false, errors.Wrap(err, "Unable to read schema version")
+	}
+
+	if tables < 2 || version < len(migrations) {
+		return true, errors.Errorf("Database schema is outdated (%d pending migrations), run a sync to migrate it", len(migrations)-version)
+	}
+
+	return true, nil
+}
+
+// migrateBrokenChecksums repairs checksums created by calling
+// h.Sum(content) on an unused hash: These consist of the hex encoded
+// content followed by the hash of no data, so the real checksum can
+// be computed from the stored value without accessing the file.
+func migrateBrokenChecksums(tx *sql.Tx) error {
+	var hashes = []func() hash.Hash{md5.New, sha1.New, sha256.New}
+
+	for _, table := range []string{sideLocal, sideRemote} {
+		// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
+		rows, err := tx.Query(fmt.Sprintf("SELECT relative_name, checksum FROM %s_state WHERE checksum != ''", table))
+		if err != nil {
+			return errors.Wrapf(err, "Unable to query table %s", table)
+		}
+
+		fixed := map[string]string{}
+		for rows.Next() {
+			var relativeName, checksum string
+			if err = rows.Scan(&relativeName, &checksum); err != nil {
+				rows.Close()
+				return errors.Wrap(err, "Unable to read response")
+			}
+
+			for _, hf := range hashes {
+				emptySum := fmt.Sprintf("%x", hf().Sum(nil))
+				if checksum == emptySum || !strings.HasSuffix(checksum, emptySum) {
+					// Either a valid checksum of an empty file or not created by this hash
+					continue
+				}
+
+				content, err := hex.DecodeString(strings.TrimSuffix(checksum, emptySum))
+				if err != nil {
+					continue
+				}
+
+				h := hf()
+				h.Write(content)
+				fixed[relativeName] = fmt.Sprintf("%x", h.Sum(nil))
+				break
+			}
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return errors.Wrap(err, "Unable to iterate rows")
+		}
+
+		for relativeName, checksum := range fixed {
+			// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
+			if _, err = tx.Exec(fmt.Sprintf("UPDATE %s_state SET checksum = ? WHERE relative_name = ?", table), checksum, relativeName); err != nil {
+				return errors.Wrap(err, "Unable to update checksum")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// brokenChecksum reproduces checksums created by h.Sum(content)
+func brokenChecksum(h hash.Hash, content string) string {
+	return fmt.Sprintf("%x", h.Sum([]byte(content)))
+}
+
+func TestMigrateBrokenChecksums(t *testing.T) {
+	s := newTestSync(t, Config{})
+
+	// Database created before the migration existed
+	if _, err := s.db.Exec(schema); err != nil {
+		t.Fatalf("Unable to create schema: %s", err)
+	}
+
+	var (
+		validMD5  = fmt.Sprintf("%x", md5.Sum([]byte("valid")))
+		emptySHA  = fmt.Sprintf("%x", sha256.Sum256(nil))
+		stored    = map[string]map[string]string{}
+		converted = map[string]map[string]string{}
+	)
+
+	for _, side := range []string{sideLocal, sideRemote} {
+		stored[side] = map[string]string{
+			"broken-md5.txt":    brokenChecksum(md5.New(), side+" md5"),
+			"broken-sha256.txt": brokenChecksum(sha256.New(), side+" sha256"),
+			"valid.txt":         validMD5,
+			"empty.txt":         emptySHA,
+			"unknown.txt":       "",
+		}
+
+		converted[side] = map[string]string{
+			"broken-md5.txt":    fmt.Sprintf("%x", md5.Sum([]byte(side+" md5"))),
+			"broken-sha256.txt": fmt.Sprintf("%x", sha256.Sum256([]byte(side+" sha256"))),
+			"valid.txt":         validMD5,
+			"empty.txt":         emptySHA,
+			"unknown.txt":       "",
+		}
+
+		for relativeName, checksum := range stored[side] {
+			// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
+			if _, err := s.db.Exec(fmt.Sprintf("INSERT INTO %s_state VALUES (?, CURRENT_TIMESTAMP, ?, 0)", side), relativeName, checksum); err != nil {
+				t.Fatalf("Unable to insert file info: %s", err)
+			}
+		}
+	}
+
+	if err := s.initSchema(); err != nil {
+		t.Fatalf("Unable to migrate database: %s", err)
+	}
+
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != len(migrations) {
+		t.Errorf("Expected user_version %d, got %d (%v)", len(migrations), version, err)
+	}
+
+	for _, side := range []string{sideLocal, sideRemote} {
+		for relativeName, expected := range converted[side] {
+			var checksum string
+			// #nosec G201 - fmt is only used to prefix a table with a constant, no user input
+			if err := s.db.QueryRow(fmt.Sprintf("SELECT checksum FROM %s_state WHERE relative_name = ?", side), relativeName).Scan(&checksum); err != nil {
+				t.Fatalf("Unable to read checksum: %s", err)
+			}
+
+			if checksum != expected {
+				t.Errorf("Expected %s checksum of %q to be %q, got %q", side, relativeName, expected, checksum)
+			}
+		}
+	}
+}
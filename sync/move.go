@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// move pairs a file deleted on one side with a file of identical
+// content added on the same side under another name
+type move struct {
+	action   Action
+	from, to string
+}
+
+// detectMoves pairs deletions and additions with identical checksum
+// and size. Moves are only detected if the side receiving the change
+// supports them, otherwise the files are transferred and deleted.
+func (s *Sync) detectMoves(syncState *state, fileNames []string) map[string]move {
+	var moves = map[string]move{}
+
+	for _, dir := range []struct {
+		action   Action
+		add, del Change
+		target   providers.CloudProvider
+		isLocal  bool
+	}{
+		{ActionMoveRemote, ChangeLocalAdd, ChangeLocalDelete, s.remote, true},
+		{ActionMoveLocal, ChangeRemoteAdd, ChangeRemoteDelete, s.local, false},
+	} {
+		if !dir.target.Capabilities().Has(providers.CapMove) {
+			continue
+		}
+
+		// Sources are queued per content to pair duplicates in a stable order
+		var deleted = map[string][]string{}
+		for _, fileName := range fileNames {
+			if !syncState.GetChangeFor(fileName).Is(dir.del) {
+				continue
+			}
+
+			var (
+				localDB, remoteDB     = syncState.GetDBInfo(fileName)
+				localScan, remoteScan = syncState.GetScanInfo(fileName)
+				source, target        = localDB, remoteScan
+			)
+			if !dir.isLocal {
+				source, target = remoteDB, localScan
+			}
+
+			if key := moveKey(source); key != "" && target != nil {
+				deleted[key] = append(deleted[key], fileName)
+			}
+		}
+
+		for _, fileName := range fileNames {
+			if !syncState.GetChangeFor(fileName).Is(dir.add) {
+				continue
+			}
+
+			var (
+				localScan, remoteScan = syncState.GetScanInfo(fileName)
+				source, target        = localScan, remoteScan
+			)
+			if !dir.isLocal {
+				source, target = remoteScan, localScan
+			}
+
+			key := moveKey(source)
+			if key == "" || target != nil || len(deleted[key]) == 0 {
+				continue
+			}
+
+			moves[fileName] = move{action: dir.action, from: deleted[key][0], to: fileName}
+			deleted[key] = deleted[key][1:]
+		}
+	}
+
+	return moves
+}
+
+// moveKey identifies the content of a file, files without checksum
+// cannot be paired safely
+func moveKey(info *providers.FileInfo) string {
+	if info == nil || info.Checksum == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", info.Checksum, info.Size)
+}
+
+// executeMove moves the file on the other side. If the move fails the
+// target is transferred instead and false is returned: The source then
+// needs to be deleted in the delete phase.
+func (s *Sync) executeMove(syncState *state, mv move, summary *RunSummary) (bool, error) {
+	var (
+		logger = s.log.WithFields(log.Fields{"filename": mv.to, "moved_from": mv.from})
+
+		source, target         = s.local, s.remote
+		sourceSide, targetSide = sideLocal, sideRemote
+	)
+
+	if mv.action == ActionMoveLocal {
+		source, target = s.remote, s.local
+		sourceSide, targetSide = sideRemote, sideLocal
+	}
+
+	logger.WithField("action", mv.action).Debug("File moved, moving on other side...")
+	if err := s.moveFile(source, target, sourceSide, targetSide, mv.from, mv.to); err != nil {
+		logger.WithError(err).Warn("Unable to move file, falling back to transfer")
+		return false, s.decideAction(syncState, mv.to, summary)
+	}
+	summary.Moved++
+
+	return true, nil
+}
+
+// pruneMoveSources lets providers remove directories left empty by
+// moving files out of them
+func (s *Sync) pruneMoveSources(moves map[string]move) {
+	for _, mv := range moves {
+		target := s.remote
+		if mv.action == ActionMoveLocal {
+			target = s.local
+		}
+
+		pp, ok := target.(providers.Pruner)
+		if !ok {
+			continue
+		}
+
+		if err := pp.PruneEmptyParents(mv.from); err != nil {
+			s.log.WithError(err).WithField("filename", mv.from).Error("Unable to remove empty parent directories")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// pruneRecorder records the files whose parents were pruned on the
+// wrapped provider
+type pruneRecorder struct {
+	providers.CloudProvider
+	pruned []string
+}
+
+func (p *pruneRecorder) PruneEmptyParents(relativeName string) error {
+	p.pruned = append(p.pruned, relativeName)
+	return nil
+}
+
+func TestMovePrunesSourceAfterSync(t *testing.T) {
+	s := newTestSync(t, Config{})
+	recorder := &pruneRecorder{CloudProvider: s.remote}
+	s.remote = recorder
+
+	modified := time.Now().Add(-time.Hour)
+	putTestFile(t, s.local, "dir/file.txt", "content", modified)
+	runTestSync(t, s)
+
+	if err := s.local.DeleteFile("dir/file.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+	putTestFile(t, s.local, "other/file.txt", "content", modified)
+
+	if summary := runTestSync(t, s); summary.Moved != 1 {
+		t.Fatalf("Expected one move, got %s", summary)
+	}
+
+	if _, err := s.remote.GetFile("other/file.txt"); err != nil {
+		t.Errorf("Moved file is missing on remote: %s", err)
+	}
+
+	if len(recorder.pruned) != 1 || recorder.pruned[0] != "dir/file.txt" {
+		t.Errorf("Expected parents of dir/file.txt to be pruned, got %v", recorder.pruned)
+	}
+}
+
+// failingMover records the writes to the wrapped provider and fails
+// every move
+type failingMover struct {
+	providers.CloudProvider
+	ops []string
+}
+
+func (f *failingMover) DeleteFile(relativeName string) error {
+	f.ops = append(f.ops, "delete "+relativeName)
+	return f.CloudProvider.DeleteFile(relativeName)
+}
+
+func (f *failingMover) MoveFile(from, to string) (providers.File, error) {
+	return nil, errors.New("move failed")
+}
+
+func (f *failingMover) PutFile(file providers.File) (providers.File, error) {
+	f.ops = append(f.ops, "put "+file.Info().RelativeName)
+	return f.CloudProvider.PutFile(file)
+}
+
+// prepareLocalMove syncs the files and afterwards moves them locally
+// into the "moved" directory
+func prepareLocalMove(t *testing.T, s *Sync, fileNames ...string) {
+	modified := time.Now().Add(-time.Hour)
+	for _, fileName := range fileNames {
+		putTestFile(t, s.local, fileName, "content of "+fileName, modified)
+	}
+	runTestSync(t, s)
+
+	for _, fileName := range fileNames {
+		if err := s.local.DeleteFile(fileName); err != nil {
+			t.Fatalf("Unable to delete file: %s", err)
+		}
+		putTestFile(t, s.local, "moved/"+fileName, "content of "+fileName, modified)
+	}
+}
+
+func TestDetectMoves(t *testing.T) {
+	s := newTestSync(t, Config{})
+
+	modified := time.Now().Add(-time.Hour)
+	putTestFile(t, s.local, "a.txt", "same", modified)
+	putTestFile(t, s.local, "b.txt", "same", modified)
+	putTestFile(t, s.local, "c.txt", "other", modified)
+	runTestSync(t, s)
+
+	for _, fileName := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := s.local.DeleteFile(fileName); err != nil {
+			t.Fatalf("Unable to delete file: %s", err)
+		}
+	}
+	putTestFile(t, s.local, "x.txt", "same", modified)
+	putTestFile(t, s.local, "y.txt", "same", modified)
+	putTestFile(t, s.local, "z.txt", "changed", modified)
+
+	syncState, err := s.buildState()
+	if err != nil {
+		t.Fatalf("Unable to build state: %s", err)
+	}
+
+	moves := s.detectMoves(syncState, syncState.GetRelativeNames())
+
+	// Duplicates are paired in a stable order, changed content is no move
+	expected := map[string]string{"x.txt": "a.txt", "y.txt": "b.txt"}
+	if len(moves) != len(expected) {
+		t.Fatalf("Expected %d moves, got %+v", len(expected), moves)
+	}
+
+	for to, from := range expected {
+		if mv := moves[to]; mv.from != from || mv.action != ActionMoveRemote {
+			t.Errorf("Expected %s to be moved remotely from %s, got %+v", to, from, mv)
+		}
+	}
+}
+
+func TestFailedMoveFallsBackToTransferAndDelete(t *testing.T) {
+	s := newTestSync(t, Config{})
+	mover := &failingMover{CloudProvider: s.remote}
+	s.remote = mover
+
+	prepareLocalMove(t, s, "file.txt")
+	mover.ops = nil
+
+	summary := runTestSync(t, s)
+	if summary.Moved != 0 || summary.Uploaded != 1 || summary.Deleted != 1 {
+		t.Errorf("Expected transfer and delete instead of a move, got %s", summary)
+	}
+
+	if len(mover.ops) != 2 || mover.ops[0] != "put moved/file.txt" || mover.ops[1] != "delete file.txt" {
+		t.Errorf("Expected transfer before delete, got %v", mover.ops)
+	}
+}
+
+func TestMoveSourcesCountAsDeletions(t *testing.T) {
+	s := newTestSync(t, Config{MaxDeleteCount: 1})
+	prepareLocalMove(t, s, "a.txt", "b.txt")
+
+	if _, err := s.RunOnce(context.Background()); err == nil {
+		t.Fatal("Expected moves exceeding the delete threshold to be refused")
+	}
+
+	if _, err := s.remote.GetFile("a.txt"); err != nil {
+		t.Errorf("Expected move source to be kept: %s", err)
+	}
+
+	s.conf.Force = true
+	if summary := runTestSync(t, s); summary.Moved != 2 {
+		t.Errorf("Expected forced sync to move both files, got %s", summary)
+	}
+}
@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+func TestPlanDoesNotInitializeDatabase(t *testing.T) {
+	s := newTestSync(t, Config{})
+	putTestFile(t, s.local, "file.txt", "content", time.Now())
+
+	plan, err := s.Plan()
+	if err != nil {
+		t.Fatalf("Unable to plan: %s", err)
+	}
+
+	if len(plan) != 1 || plan[0].Action != ActionUpload {
+		t.Errorf("Expected a single upload, got %+v", plan)
+	}
+
+	var tables int
+	if err = s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		t.Fatalf("Unable to read schema: %s", err)
+	}
+
+	if tables != 0 {
+		t.Errorf("Expected plan not to create tables, found %d", tables)
+	}
+}
+
+func TestPlanRefusesPendingMigrations(t *testing.T) {
+	s := newTestSync(t, Config{})
+	putTestFile(t, s.local, "file.txt", "content", time.Now())
+	runTestSync(t, s)
+
+	if _, err := s.db.Exec("PRAGMA user_version = 0"); err != nil {
+		t.Fatalf("Unable to reset schema version: %s", err)
+	}
+
+	if _, err := s.Plan(); err == nil {
+		t.Error("Expected plan to fail with pending migrations")
+	}
+
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatalf("Unable to read schema version: %s", err)
+	}
+
+	if version != 0 {
+		t.Errorf("Expected plan not to migrate, schema version is %d", version)
+	}
+}
+
+// cleanupRecorder counts the cleanups of the wrapped provider
+type cleanupRecorder struct {
+	providers.CloudProvider
+	cleanups int
+}
+
+func (c *cleanupRecorder) Cleanup() error {
+	c.cleanups++
+	return nil
+}
+
+func TestOnlySyncCleansUpProviders(t *testing.T) {
+	s := newTestSync(t, Config{})
+	recorder := &cleanupRecorder{CloudProvider: s.local}
+	s.local = recorder
+
+	if _, err := s.Plan(); err != nil {
+		t.Fatalf("Unable to plan: %s", err)
+	}
+
+	if recorder.cleanups != 0 {
+		t.Errorf("Expected plan not to clean up providers, got %d cleanups", recorder.cleanups)
+	}
+
+	runTestSync(t, s)
+
+	if recorder.cleanups != 1 {
+		t.Errorf("Expected sync to clean up providers once, got %d cleanups", recorder.cleanups)
+	}
+}
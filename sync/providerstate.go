@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+type providerStateStore struct {
+	db       *sql.DB
+	lock     *sync.Mutex
+	provider string
+}
+
+func (s *Sync) initProviderState() error {
+	for _, p := range []providers.CloudProvider{s.local, s.remote} {
+		sp, ok := p.(providers.StatefulProvider)
+		if !ok {
+			continue
+		}
+
+		if err := sp.SetStateStore(providerStateStore{db: s.db, lock: &s.dbLock, provider: p.Name()}); err != nil {
+			return errors.Wrapf(err, "Unable to initialize state for provider %s", p.Name())
+		}
+	}
+
+	return nil
+}
+
+func (p providerStateStore) DeleteState(key string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, err := p.db.Exec(`DELETE FROM provider_state WHERE provider = ? AND key = ?`, p.provider, key)
+	return errors.Wrap(err, "Unable to delete provider state")
+}
+
+func (p providerStateStore) GetState(key string) (string, error) {
+	var value string
+	err := p.db.QueryRow(`SELECT value FROM provider_state WHERE provider = ? AND key = ?`, p.provider, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, errors.Wrap(err, "Unable to read provider state")
+}
+
+func (p providerStateStore) ListStateKeys() ([]string, error) {
+	rows, err := p.db.Query(`SELECT key FROM provider_state WHERE provider = ?`, p.provider)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to query provider state")
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, errors.Wrap(err, "Unable to read response")
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, errors.Wrap(rows.Err(), "Unable to iterate rows")
+}
+
+func (p providerStateStore) SetState(key, value string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, err := p.db.Exec(
+		`INSERT INTO provider_state VALUES(?, ?, ?)
+			ON CONFLICT(provider, key) DO UPDATE SET
+				value=excluded.value`, p.provider, key, value)
+	return errors.Wrap(err, "Unable to upsert provider state")
+}
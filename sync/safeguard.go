@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+const stateKeyRootID = "root_id"
+
+// checkDeletions refuses runs deleting more files than allowed by any
+// configured threshold: An emptied sync root must not wipe the remote.
+// Thresholds set to 0 are disabled.
+func (s *Sync) checkDeletions(syncState *state, deletes []string) error {
+	if s.conf.Force || len(deletes) == 0 || (s.conf.MaxDeleteCount == 0 && s.conf.MaxDeletePercent == 0) {
+		return nil
+	}
+
+	var tracked int
+	for _, fileName := range syncState.GetRelativeNames() {
+		if localDB, remoteDB := syncState.GetDBInfo(fileName); localDB != nil || remoteDB != nil {
+			tracked++
+		}
+	}
+
+	var (
+		countExceeded   = s.conf.MaxDeleteCount > 0 && len(deletes) > s.conf.MaxDeleteCount
+		percentExceeded = s.conf.MaxDeletePercent > 0 && len(deletes)*100 > tracked*s.conf.MaxDeletePercent
+	)
+
+	if !countExceeded && !percentExceeded {
+		return nil
+	}
+
+	return errors.Errorf(
+		"Refusing to delete %d of %d tracked files (max_delete_count=%d, max_delete_percent=%d), force the sync to delete them",
+		len(deletes), tracked, s.conf.MaxDeleteCount, s.conf.MaxDeletePercent)
+}
+
+// checkSyncRoot ensures the local root still exists and is backed by
+// the same storage as during the last run
+func (s *Sync) checkSyncRoot() error {
+	ri, ok := s.local.(providers.RootIdentifier)
+	if !ok {
+		return nil
+	}
+
+	rootID, err := ri.RootID()
+	if err != nil {
+		return errors.Wrap(err, "Sync root is not available")
+	}
+
+	if rootID == "" {
+		// Provider is not able to identify the storage on this system
+		return nil
+	}
+
+	store := providerStateStore{db: s.db, lock: &s.dbLock, provider: s.local.Name()}
+
+	knownID, err := store.GetState(stateKeyRootID)
+	if err != nil {
+		return errors.Wrap(err, "Unable to read sync root device")
+	}
+
+	switch {
+	case knownID == rootID:
+		return nil
+
+	case knownID != "" && !s.conf.Force:
+		return errors.Errorf("Sync root changed its device (was %s, is %s), force the sync to accept the new device", knownID, rootID)
+
+	case knownID != "":
+		s.log.WithField("device", rootID).Warn("Sync root changed its device, accepting it as forced")
+	}
+
+	return errors.Wrap(store.SetState(stateKeyRootID, rootID), "Unable to store sync root device")
+}
@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+func TestCheckDeletions(t *testing.T) {
+	// Ten tracked files of which the given number is deleted
+	syncState := newState()
+	for i := 0; i < 10; i++ {
+		syncState.Set(sideLocal, sourceDB, providers.FileInfo{RelativeName: fmt.Sprintf("file%d.txt", i)})
+	}
+
+	for _, tc := range []struct {
+		name       string
+		conf       Config
+		deletes    int
+		expectFail bool
+	}{
+		{"disabled", Config{}, 10, false},
+		{"no deletes", Config{MaxDeleteCount: 1, MaxDeletePercent: 1}, 0, false},
+		{"count below", Config{MaxDeleteCount: 5}, 5, false},
+		{"count exceeded", Config{MaxDeleteCount: 5}, 6, true},
+		{"percent below", Config{MaxDeletePercent: 50}, 5, false},
+		{"percent exceeded", Config{MaxDeletePercent: 50}, 6, true},
+		{"both below", Config{MaxDeleteCount: 5, MaxDeletePercent: 50}, 5, false},
+		{"only count exceeded", Config{MaxDeleteCount: 2, MaxDeletePercent: 50}, 3, true},
+		{"only percent exceeded", Config{MaxDeleteCount: 100, MaxDeletePercent: 50}, 6, true},
+		{"both exceeded", Config{MaxDeleteCount: 2, MaxDeletePercent: 10}, 3, true},
+		{"forced", Config{MaxDeleteCount: 2, MaxDeletePercent: 10, Force: true}, 10, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Sync{conf: tc.conf}
+
+			var deletes []string
+			for i := 0; i < tc.deletes; i++ {
+				deletes = append(deletes, fmt.Sprintf("file%d.txt", i))
+			}
+
+			if err := s.checkDeletions(syncState, deletes); (err != nil) != tc.expectFail {
+				t.Errorf("Expected failure %v, got %v", tc.expectFail, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// Share is an entry in the registry of shared files
+type Share struct {
+	ID           int64               `json:"id"`
+	RelativeName string              `json:"relative_name"`
+	URL          string              `json:"url"`
+	Mode         providers.ShareMode `json:"mode"`
+	Creator      string              `json:"creator"`
+	CreatedAt    time.Time           `json:"created_at"`
+	ExpiresAt    *time.Time          `json:"expires_at,omitempty"`
+}
+
+func (s Share) Expired() bool {
+	return s.ExpiresAt != nil && s.ExpiresAt.Before(time.Now())
+}
+
+// Remote returns the remote provider used by the sync
+func (s *Sync) Remote() providers.CloudProvider { return s.remote }
+
+// RegisterShare records a share created through the remote provider
+func (s *Sync) RegisterShare(share Share) error {
+	if err := s.initSchema(); err != nil {
+		return errors.Wrap(err, "Unable to initialize database schema")
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO shares (relative_name, url, mode, creator, created_at, expires_at) VALUES(?, ?, ?, ?, ?, ?)`,
+		share.RelativeName, share.URL, string(share.Mode), share.Creator, share.CreatedAt, share.ExpiresAt,
+	)
+	return errors.Wrap(err, "Unable to insert share")
+}
+
+// ListShares returns all registered shares ordered by file name
+func (s *Sync) ListShares() ([]Share, error) {
+	if err := s.initSchema(); err != nil {
+		return nil, errors.Wrap(err, "Unable to initialize database schema")
+	}
+
+	rows, err := s.db.Query(`SELECT id, relative_name, url, mode, creator, created_at, expires_at FROM shares ORDER BY relative_name, created_at`)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to query shares")
+	}
+	defer rows.Close()
+
+	var shares []Share
+	for rows.Next() {
+		var (
+			share Share
+			mode  string
+		)
+
+		if err = rows.Scan(&share.ID, &share.RelativeName, &share.URL, &mode, &share.Creator, &share.CreatedAt, &share.ExpiresAt); err != nil {
+			return nil, errors.Wrap(err, "Unable to read response")
+		}
+		share.Mode = providers.ShareMode(mode)
+
+		shares = append(shares, share)
+	}
+
+	return shares, errors.Wrap(rows.Err(), "Unable to iterate rows")
+}
+
+// Unshare revokes all shares of the given file and removes them from
+// the registry. Presigned links cannot be revoked and stay valid until
+// they expire.
+func (s *Sync) Unshare(relativeName string) error {
+	shares, err := s.ListShares()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list shares")
+	}
+
+	var registered, public bool
+	for _, share := range shares {
+		if share.RelativeName != relativeName {
+			continue
+		}
+
+		registered = true
+		if share.Mode == providers.ShareModePresigned && !share.Expired() {
+			s.log.WithField("filename", relativeName).Warn("Presigned link cannot be revoked and stays valid until it expires")
+		}
+		public = public || share.Mode == providers.ShareModePublic
+	}
+
+	// Files shared before the registry existed are not registered but might be public
+	if public || !registered {
+		if err := s.remote.Unshare(relativeName); err != nil {
+			return errors.Wrap(err, "Unable to revoke share")
+		}
+	}
+
+	_, err = s.db.Exec(`DELETE FROM shares WHERE relative_name = ?`, relativeName)
+	return errors.Wrap(err, "Unable to delete share")
+}
+
+func (s *Sync) revokeExpiredShares() error {
+	shares, err := s.ListShares()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list shares")
+	}
+
+	// Unshare revokes all public shares of a file, it must wait until the
+	// last of them expired. Presigned links are not affected by it.
+	activePublic := map[string]bool{}
+	for _, share := range shares {
+		if share.Mode == providers.ShareModePublic && !share.Expired() {
+			activePublic[share.RelativeName] = true
+		}
+	}
+
+	for _, share := range shares {
+		if !share.Expired() {
+			continue
+		}
+
+		logger := s.log.WithField("filename", share.RelativeName)
+
+		if share.Mode == providers.ShareModePublic && !activePublic[share.RelativeName] {
+			if err := s.remote.Unshare(share.RelativeName); err != nil && err != providers.ErrFileNotFound {
+				return errors.Wrapf(err, "Unable to revoke share of %q", share.RelativeName)
+			}
+		}
+
+		// Public share URLs are deterministic: Other shares of the file
+		// might have the same URL
+		if _, err := s.db.Exec(`DELETE FROM shares WHERE id = ?`, share.ID); err != nil {
+			return errors.Wrap(err, "Unable to delete share")
+		}
+
+		logger.Info("Expired share removed")
+	}
+
+	return nil
+}
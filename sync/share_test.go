@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// unshareRecorder records the files unshared on the wrapped provider
+type unshareRecorder struct {
+	providers.CloudProvider
+	unshared []string
+}
+
+func (u *unshareRecorder) Unshare(relativeName string) error {
+	u.unshared = append(u.unshared, relativeName)
+	return nil
+}
+
+func TestRevokeExpiredShares(t *testing.T) {
+	s := newTestSync(t, Config{})
+	recorder := &unshareRecorder{CloudProvider: s.remote}
+	s.remote = recorder
+
+	var (
+		expired = time.Now().Add(-time.Hour)
+		valid   = time.Now().Add(time.Hour)
+	)
+
+	for _, share := range []Share{
+		{RelativeName: "expired.txt", URL: "a", Mode: providers.ShareModePublic, ExpiresAt: &expired},
+		// Public share URLs are deterministic and therefore identical
+		{RelativeName: "still-shared.txt", URL: "b", Mode: providers.ShareModePublic, ExpiresAt: &expired},
+		{RelativeName: "still-shared.txt", URL: "b", Mode: providers.ShareModePublic, ExpiresAt: &valid},
+		{RelativeName: "presigned.txt", URL: "c", Mode: providers.ShareModePublic, ExpiresAt: &expired},
+		{RelativeName: "presigned.txt", URL: "d", Mode: providers.ShareModePresigned, ExpiresAt: &valid},
+	} {
+		if err := s.RegisterShare(share); err != nil {
+			t.Fatalf("Unable to register share: %s", err)
+		}
+	}
+
+	if err := s.revokeExpiredShares(); err != nil {
+		t.Fatalf("Unable to revoke shares: %s", err)
+	}
+
+	if len(recorder.unshared) != 2 || recorder.unshared[0] != "expired.txt" || recorder.unshared[1] != "presigned.txt" {
+		t.Errorf("Expected only files without valid public shares to be unshared, got %v", recorder.unshared)
+	}
+
+	shares, err := s.ListShares()
+	if err != nil {
+		t.Fatalf("Unable to list shares: %s", err)
+	}
+
+	if len(shares) != 2 || shares[0].URL != "d" || shares[1].URL != "b" || shares[1].Expired() {
+		t.Errorf("Expected only valid shares to remain registered, got %+v", shares)
+	}
+}
@@ -79,6 +79,30 @@ func (s *state) GetChangeFor(relativeName string) (result Change) {
 	return result
 }
 
+func (s *state) GetDBInfo(relativeName string) (local, remote *providers.FileInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d, ok := s.files[relativeName]
+	if !ok {
+		return nil, nil
+	}
+
+	return d.LocalDB, d.RemoteDB
+}
+
+func (s *state) GetScanInfo(relativeName string) (local, remote *providers.FileInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d, ok := s.files[relativeName]
+	if !ok {
+		return nil, nil
+	}
+
+	return d.LocalScan, d.RemoteScan
+}
+
 func (s *state) GetRelativeNames() []string {
 	s.lock.Lock()
 	defer s.lock.Unlock()
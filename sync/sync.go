@@ -1,30 +1,78 @@
 package sync
 
 import (
+	"context"
 	"database/sql"
-	"hash"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/Luzifer/cloudbox/ignore"
 	"github.com/Luzifer/cloudbox/providers"
 )
 
 type Config struct {
-	ForceUseChecksum bool          `yaml:"force_use_checksum"`
-	ScanInterval     time.Duration `yaml:"scan_interval"`
+	Concurrency            int                    `yaml:"concurrency"`
+	ConflictStrategy       ConflictStrategy       `yaml:"conflict_strategy"`
+	DeleteConflictStrategy DeleteConflictStrategy `yaml:"delete_conflict_strategy"`
+	ForceUseChecksum       bool                   `yaml:"force_use_checksum"`
+	IgnorePatterns         []string               `yaml:"ignore_patterns"`
+	MaxDeleteCount         int                    `yaml:"max_delete_count"`
+	MaxDeletePercent       int                    `yaml:"max_delete_percent"`
+	ScanInterval           time.Duration          `yaml:"scan_interval"`
+	WatchDebounce          time.Duration          `yaml:"watch_debounce"`
+	WatchLocal             bool                   `yaml:"watch_local"`
+
+	// Force overrides the safeguards against mass deletions and a
+	// changed sync root, it is set from the command line only
+	Force bool `yaml:"-"`
+}
+
+func (c Config) Validate() error {
+	if c.Concurrency < 0 {
+		return errors.Errorf("Invalid concurrency %d", c.Concurrency)
+	}
+
+	if !c.ConflictStrategy.IsValid() {
+		return errors.Errorf("Unknown conflict strategy %q", c.ConflictStrategy)
+	}
+
+	if !c.DeleteConflictStrategy.IsValid() {
+		return errors.Errorf("Unknown delete conflict strategy %q", c.DeleteConflictStrategy)
+	}
+
+	if c.MaxDeleteCount < 0 {
+		return errors.Errorf("Invalid max delete count %d", c.MaxDeleteCount)
+	}
+
+	if c.MaxDeletePercent < 0 || c.MaxDeletePercent > 100 {
+		return errors.Errorf("Invalid max delete percent %d", c.MaxDeletePercent)
+	}
+
+	return nil
+}
+
+// workers returns the number of files to process in parallel
+func (c Config) workers() int {
+	if c.Concurrency < 1 {
+		return 1
+	}
+	return c.Concurrency
 }
 
 type Sync struct {
 	db            *sql.DB
+	dbLock        sync.Mutex
 	conf          Config
 	local, remote providers.CloudProvider
 
 	log *log.Entry
 
 	useChecksum bool
-	hashMethod  hash.Hash
+
+	ignore *ignore.Matcher
 
 	stop chan struct{}
 }
@@ -47,22 +95,98 @@ func (s *Sync) Run() error {
 		return errors.Wrap(err, "Unable to initialize database schema")
 	}
 
-	var refresh = time.NewTimer(s.conf.ScanInterval)
+	if err := s.initProviderState(); err != nil {
+		return errors.Wrap(err, "Unable to initialize provider state")
+	}
+
+	if err := s.cleanupProviders(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		refresh  = time.NewTimer(s.conf.ScanInterval)
+		debounce = time.NewTimer(s.conf.WatchDebounce)
+		pending  = map[string]struct{}{}
+
+		watchChanges = make(chan string, 100)
+		watchErrors  = make(chan error, 1)
+	)
+	debounce.Stop()
+
+	if s.conf.WatchLocal {
+		if !s.local.Capabilities().Has(providers.CapWatch) {
+			return errors.New("Local provider does not support watching")
+		}
+
+		go func() { watchErrors <- s.local.Watch(ctx, watchChanges) }()
+	}
 
 	for {
 		select {
 		case <-refresh.C:
-			if err := s.runSync(); err != nil {
+			// Periodic full rescan as safety net for missed watch events
+			if _, err := s.runSync(ctx); err != nil {
 				return errors.Wrap(err, "Sync failed")
 			}
 			refresh.Reset(s.conf.ScanInterval)
 
+		case relativeName := <-watchChanges:
+			pending[relativeName] = struct{}{}
+			debounce.Stop()
+			debounce.Reset(s.conf.WatchDebounce)
+
+		case <-debounce.C:
+			var names []string
+			for name := range pending {
+				names = append(names, name)
+			}
+			pending = map[string]struct{}{}
+
+			if _, err := s.runSyncFor(ctx, names); err != nil {
+				return errors.Wrap(err, "Targeted sync failed")
+			}
+
+		case err := <-watchErrors:
+			if err != nil {
+				return errors.Wrap(err, "Unable to watch local files")
+			}
+
 		case <-s.stop:
 			return nil
 		}
 	}
 }
 
+// RunOnce executes exactly one sync pass and returns an error if the
+// pass or any of the file actions within it failed
+func (s *Sync) RunOnce(ctx context.Context) (RunSummary, error) {
+	if err := s.initSchema(); err != nil {
+		return RunSummary{}, errors.Wrap(err, "Unable to initialize database schema")
+	}
+
+	if err := s.initProviderState(); err != nil {
+		return RunSummary{}, errors.Wrap(err, "Unable to initialize provider state")
+	}
+
+	if err := s.cleanupProviders(); err != nil {
+		return RunSummary{}, err
+	}
+
+	summary, err := s.runSync(ctx)
+	if err != nil {
+		return summary, errors.Wrap(err, "Sync failed")
+	}
+
+	if summary.Failed > 0 {
+		return summary, errors.Errorf("%d file actions failed", summary.Failed)
+	}
+
+	return summary, nil
+}
+
 func (s *Sync) Stop() { s.stop <- struct{}{} }
 
 func (s *Sync) getFileInfo(f providers.File) (providers.FileInfo, error) {
@@ -72,7 +196,8 @@ func (s *Sync) getFileInfo(f providers.File) (providers.FileInfo, error) {
 		return info, nil
 	}
 
-	cs, err := f.Checksum(s.hashMethod)
+	// Hashes are stateful: Every file needs a fresh one
+	cs, err := f.Checksum(s.remote.GetChecksumMethod())
 	if err != nil {
 		return info, errors.Wrap(err, "Unable to fetch checksum")
 	}
@@ -87,7 +212,15 @@ func (s *Sync) fillStateFromProvider(syncState *state, provider providers.CloudP
 		return errors.Wrap(err, "Unable to list files")
 	}
 
+	return s.fillStateFromFiles(syncState, files, side)
+}
+
+func (s *Sync) fillStateFromFiles(syncState *state, files []providers.File, side string) error {
 	for _, f := range files {
+		if s.ignore.Ignored(f.Info().RelativeName) {
+			continue
+		}
+
 		info, err := s.getFileInfo(f)
 		if err != nil {
 			return errors.Wrap(err, "Unable to get file info")
@@ -99,28 +232,145 @@ func (s *Sync) fillStateFromProvider(syncState *state, provider providers.CloudP
 	return nil
 }
 
-func (s *Sync) runSync() error {
+// Plan lists the actions a sync run would execute. The database is
+// only read: Pending migrations must be applied by a sync run first.
+func (s *Sync) Plan() ([]PlannedAction, error) {
+	initialized, err := s.checkSchema()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to use database")
+	}
+
 	var syncState = newState()
-	s.hashMethod = s.remote.GetChecksumMethod()
+	if initialized {
+		if err = s.updateStateFromDatabase(syncState); err != nil {
+			return nil, errors.Wrap(err, "Unable to load database state")
+		}
+	}
+
+	if err = s.scanIntoState(syncState); err != nil {
+		return nil, err
+	}
+
+	var (
+		fileNames = s.syncableNames(syncState)
+		moves     = s.detectMoves(syncState, fileNames)
+		movedFrom = map[string]bool{}
+		plan      []PlannedAction
+	)
+
+	for _, mv := range moves {
+		movedFrom[mv.from] = true
+	}
+
+	for _, fileName := range fileNames {
+		var (
+			change = syncState.GetChangeFor(fileName)
+			action = s.planAction(change)
+		)
+
+		if action == ActionNone || movedFrom[fileName] {
+			continue
+		}
+
+		pa := PlannedAction{
+			RelativeName: fileName,
+			Action:       action,
+			Change:       change.String(),
+		}
+
+		if mv, ok := moves[fileName]; ok {
+			pa.Action = mv.action
+			pa.MovedFrom = mv.from
+		}
+
+		if action == ActionConflict {
+			pa.Strategy = string(ConflictManual)
+			if s.conf.ConflictStrategy != "" {
+				pa.Strategy = string(s.conf.ConflictStrategy)
+			}
+		}
+
+		plan = append(plan, pa)
+	}
+
+	return plan, nil
+}
+
+// cleanupProviders lets providers remove leftovers of interrupted runs
+func (s *Sync) cleanupProviders() error {
+	for _, p := range []providers.CloudProvider{s.local, s.remote} {
+		if c, ok := p.(providers.Cleaner); ok {
+			if err := c.Cleanup(); err != nil {
+				return errors.Wrapf(err, "Unable to clean up provider %s", p.Name())
+			}
+		}
+	}
+
+	return nil
+}
+
+// purgeProviders lets providers clean up expired deleted files
+func (s *Sync) purgeProviders() {
+	for _, p := range []providers.CloudProvider{s.local, s.remote} {
+		if pp, ok := p.(providers.Purger); ok {
+			if err := pp.Purge(); err != nil {
+				s.log.WithError(err).WithField("provider", p.Name()).Error("Unable to purge expired files")
+			}
+		}
+	}
+}
+
+func (s *Sync) initChecksumMethod() {
 	s.useChecksum = s.remote.Capabilities().Has(providers.CapAutoChecksum) || s.conf.ForceUseChecksum
+}
+
+func (s *Sync) buildState() (*state, error) {
+	var syncState = newState()
 
 	if err := s.updateStateFromDatabase(syncState); err != nil {
-		return errors.Wrap(err, "Unable to load database state")
+		return nil, errors.Wrap(err, "Unable to load database state")
 	}
 
-	if err := s.fillStateFromProvider(syncState, s.local, sideLocal); err != nil {
-		return errors.Wrap(err, "Unable to load local files")
+	return syncState, s.scanIntoState(syncState)
+}
+
+// scanIntoState adds the current files of both sides to the state
+func (s *Sync) scanIntoState(syncState *state) error {
+	s.initChecksumMethod()
+
+	localFiles, err := s.local.ListFiles()
+	if err != nil {
+		return errors.Wrap(err, "Unable to list local files")
+	}
+
+	if s.ignore, err = s.loadIgnoreRules(localFiles); err != nil {
+		return errors.Wrap(err, "Unable to load ignore rules")
 	}
 
-	if err := s.fillStateFromProvider(syncState, s.remote, sideRemote); err != nil {
-		return errors.Wrap(err, "Unable to load remote files")
+	if err := s.fillStateFromFiles(syncState, localFiles, sideLocal); err != nil {
+		return errors.Wrap(err, "Unable to load local files")
 	}
 
-	for _, fileName := range syncState.GetRelativeNames() {
-		if err := s.decideAction(syncState, fileName); err != nil {
-			return errors.Wrap(err, "Could not execute sync")
+	return errors.Wrap(s.fillStateFromProvider(syncState, s.remote, sideRemote), "Unable to load remote files")
+}
+
+func (s *Sync) runSync(ctx context.Context) (RunSummary, error) {
+	if s.remote.Capabilities().Has(providers.CapShare) {
+		if err := s.revokeExpiredShares(); err != nil {
+			s.log.WithError(err).Error("Unable to revoke expired shares")
 		}
 	}
 
-	return nil
+	s.purgeProviders()
+
+	if err := s.checkSyncRoot(); err != nil {
+		return RunSummary{}, err
+	}
+
+	syncState, err := s.buildState()
+	if err != nil {
+		return RunSummary{}, err
+	}
+
+	return s.executeActions(ctx, syncState, s.syncableNames(syncState))
 }
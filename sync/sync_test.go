@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/cloudbox/providers"
+	"github.com/Luzifer/cloudbox/providers/memory"
+)
+
+// testFile is used to put content into the providers under test
+type testFile struct {
+	relativeName string
+	lastModified time.Time
+	content      []byte
+}
+
+func (f testFile) Info() providers.FileInfo {
+	return providers.FileInfo{
+		RelativeName: f.relativeName,
+		LastModified: f.lastModified,
+		Size:         uint64(len(f.content)),
+	}
+}
+
+func (f testFile) Checksum(h hash.Hash) (string, error) {
+	h.Write(f.content)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (f testFile) Content() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+// newTestSync creates a sync between two empty memory providers backed
+// by a fresh database
+func newTestSync(t *testing.T, conf Config) *Sync {
+	dir, err := ioutil.TempDir("", "cloudbox-sync")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := sql.Open("sqlite3", path.Join(dir, "sync.db"))
+	if err != nil {
+		t.Fatalf("Unable to open database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	local, _ := memory.New("memory://local")
+	remote, _ := memory.New("memory://remote")
+
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+
+	return New(local, remote, db, conf, log.NewEntry(logger))
+}
+
+func putTestFile(t *testing.T, p providers.CloudProvider, relativeName, content string, lastModified time.Time) {
+	if _, err := p.PutFile(testFile{relativeName: relativeName, lastModified: lastModified, content: []byte(content)}); err != nil {
+		t.Fatalf("Unable to put %q: %s", relativeName, err)
+	}
+}
+
+func runTestSync(t *testing.T, s *Sync) RunSummary {
+	summary, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %s", err)
+	}
+	return summary
+}
+
+// purgeRecorder counts the purges of the wrapped provider
+type purgeRecorder struct {
+	providers.CloudProvider
+	purges int
+}
+
+func (p *purgeRecorder) Purge() error {
+	p.purges++
+	return nil
+}
+
+func TestRunPurgesProviders(t *testing.T) {
+	s := newTestSync(t, Config{})
+	recorder := &purgeRecorder{CloudProvider: s.local}
+	s.local = recorder
+
+	runTestSync(t, s)
+	runTestSync(t, s)
+
+	if recorder.purges != 2 {
+		t.Errorf("Expected a purge on every run, got %d", recorder.purges)
+	}
+}
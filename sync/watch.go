@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Luzifer/cloudbox/ignore"
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+func (s *Sync) fillStateFromFile(syncState *state, provider providers.CloudProvider, side, relativeName string) error {
+	f, err := provider.GetFile(relativeName)
+	switch err {
+	case nil:
+	case providers.ErrFileNotFound:
+		// File vanished or never existed, nothing to record
+		return nil
+	default:
+		return errors.Wrap(err, "Unable to get file")
+	}
+
+	info, err := s.getFileInfo(f)
+	if err != nil {
+		return errors.Wrap(err, "Unable to get file info")
+	}
+
+	syncState.Set(side, sourceScan, info)
+	return nil
+}
+
+// runSyncFor executes a sync restricted to the given relative names
+// and all tracked files below them in case they denote directories
+func (s *Sync) runSyncFor(ctx context.Context, names []string) (RunSummary, error) {
+	var (
+		summary   RunSummary
+		syncState = newState()
+	)
+	s.initChecksumMethod()
+
+	if err := s.checkSyncRoot(); err != nil {
+		return summary, err
+	}
+
+	if err := s.updateStateFromDatabase(syncState); err != nil {
+		return summary, errors.Wrap(err, "Unable to load database state")
+	}
+
+	var (
+		affected     = map[string]bool{}
+		reloadIgnore = s.ignore == nil
+	)
+	for _, name := range names {
+		affected[name] = true
+		reloadIgnore = reloadIgnore || path.Base(name) == ignore.FileName
+		for _, known := range syncState.GetRelativeNames() {
+			if strings.HasPrefix(known, name+"/") {
+				affected[known] = true
+			}
+		}
+	}
+
+	if reloadIgnore {
+		localFiles, err := s.local.ListFiles()
+		if err != nil {
+			return summary, errors.Wrap(err, "Unable to list local files")
+		}
+
+		if s.ignore, err = s.loadIgnoreRules(localFiles); err != nil {
+			return summary, errors.Wrap(err, "Unable to load ignore rules")
+		}
+	}
+
+	for name := range affected {
+		if s.ignore.Ignored(name) {
+			continue
+		}
+
+		if err := s.fillStateFromFile(syncState, s.local, sideLocal, name); err != nil {
+			return summary, errors.Wrapf(err, "Unable to load local file %q", name)
+		}
+
+		if err := s.fillStateFromFile(syncState, s.remote, sideRemote, name); err != nil {
+			return summary, errors.Wrapf(err, "Unable to load remote file %q", name)
+		}
+	}
+
+	var fileNames []string
+	for _, fileName := range s.syncableNames(syncState) {
+		if affected[fileName] {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	return s.executeActions(ctx, syncState, fileNames)
+}
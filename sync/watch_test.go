@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// watchedProvider reports the changes sent by the test as changes
+// detected by watching the wrapped provider
+type watchedProvider struct {
+	providers.CloudProvider
+	changes chan string
+}
+
+func (w watchedProvider) Capabilities() providers.Capability {
+	return w.CloudProvider.Capabilities() | providers.CapWatch
+}
+
+func (w watchedProvider) Watch(ctx context.Context, changes chan<- string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case relativeName := <-w.changes:
+			changes <- relativeName
+		}
+	}
+}
+
+// putRecorder records when files were put into the wrapped provider
+type putRecorder struct {
+	providers.CloudProvider
+
+	lock sync.Mutex
+	puts map[string]time.Time
+}
+
+func (p *putRecorder) PutFile(f providers.File) (providers.File, error) {
+	p.lock.Lock()
+	p.puts[f.Info().RelativeName] = time.Now()
+	p.lock.Unlock()
+
+	return p.CloudProvider.PutFile(f)
+}
+
+func (p *putRecorder) putAt(relativeName string) (time.Time, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	t, ok := p.puts[relativeName]
+	return t, ok
+}
+
+// startWatchSync runs the sync in watch mode without periodic rescans
+// and returns the channel to report local changes through
+func startWatchSync(t *testing.T, s *Sync) chan string {
+	changes := make(chan string)
+	s.local = watchedProvider{CloudProvider: s.local, changes: changes}
+
+	errC := make(chan error, 1)
+	go func() { errC <- s.Run() }()
+
+	t.Cleanup(func() {
+		s.Stop()
+		if err := <-errC; err != nil {
+			t.Errorf("Watch sync failed: %s", err)
+		}
+	})
+
+	return changes
+}
+
+// waitFor polls the condition until it is met or the timeout passed
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return condition()
+}
+
+func TestWatchDebouncesChanges(t *testing.T) {
+	const debounce = 200 * time.Millisecond
+
+	s := newTestSync(t, Config{WatchLocal: true, WatchDebounce: debounce, ScanInterval: time.Hour})
+	recorder := &putRecorder{CloudProvider: s.remote, puts: map[string]time.Time{}}
+	s.remote = recorder
+
+	changes := startWatchSync(t, s)
+
+	putTestFile(t, s.local, "a.txt", "a", time.Now())
+	changes <- "a.txt"
+	time.Sleep(debounce / 2)
+
+	putTestFile(t, s.local, "b.txt", "b", time.Now())
+	changes <- "b.txt"
+	lastChange := time.Now()
+
+	if !waitFor(func() bool { _, ok := recorder.putAt("b.txt"); return ok }) {
+		t.Fatal("Changed files were not synced")
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		putAt, ok := recorder.putAt(name)
+		if !ok {
+			t.Fatalf("Expected %q to be synced together with the last change", name)
+		}
+
+		if putAt.Sub(lastChange) < debounce {
+			t.Errorf("Expected %q to be synced after the debounce interval, synced %s after the last change", name, putAt.Sub(lastChange))
+		}
+	}
+}
+
+func TestWatchSyncsOnlyChangedPaths(t *testing.T) {
+	s := newTestSync(t, Config{WatchLocal: true, WatchDebounce: 10 * time.Millisecond, ScanInterval: time.Hour})
+
+	putTestFile(t, s.local, "dir/tracked.txt", "tracked", time.Now().Add(-time.Hour))
+	runTestSync(t, s)
+
+	// A removed directory is reported as a single change
+	if err := s.local.DeleteFile("dir/tracked.txt"); err != nil {
+		t.Fatalf("Unable to delete file: %s", err)
+	}
+	putTestFile(t, s.local, "changed.txt", "changed", time.Now())
+	putTestFile(t, s.local, "unreported.txt", "unreported", time.Now())
+
+	changes := startWatchSync(t, s)
+	changes <- "dir"
+	changes <- "changed.txt"
+
+	if !waitFor(func() bool {
+		_, err := s.remote.GetFile("dir/tracked.txt")
+		return err == providers.ErrFileNotFound
+	}) {
+		t.Error("Expected files below the changed directory to be synced")
+	}
+
+	if !waitFor(func() bool { _, err := s.remote.GetFile("changed.txt"); return err == nil }) {
+		t.Error("Expected changed file to be synced")
+	}
+
+	if _, err := s.remote.GetFile("unreported.txt"); err != providers.ErrFileNotFound {
+		t.Errorf("Expected unreported file not to be synced, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package sync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// executeActions runs the actions for the given files using the
+// configured number of workers. Nothing is executed if the deletions
+// exceed the configured thresholds. Deletions are started only after all
+// other actions finished: Moved files not detected as such show up as
+// delete and add and must never be missing on both sides in case the
+// transfer fails. Directories left empty by moves are pruned last.
+func (s *Sync) executeActions(ctx context.Context, syncState *state, fileNames []string) (RunSummary, error) {
+	var (
+		summary          RunSummary
+		deletes, updates []string
+
+		moves     = s.detectMoves(syncState, fileNames)
+		movedFrom = map[string]bool{}
+
+		fallbackDeletes []string
+		fallbackLock    sync.Mutex
+	)
+
+	for _, mv := range moves {
+		movedFrom[mv.from] = true
+	}
+
+	for _, fileName := range fileNames {
+		if movedFrom[fileName] {
+			// Handled together with the move target
+			continue
+		}
+
+		switch s.planAction(syncState.GetChangeFor(fileName)) {
+		case ActionDeleteLocal, ActionDeleteRemote:
+			deletes = append(deletes, fileName)
+		default:
+			updates = append(updates, fileName)
+		}
+	}
+
+	// Sources of failed moves are deleted after transferring the target
+	// so they need to pass the safeguard as deletions
+	checked := append([]string{}, deletes...)
+	for fileName := range movedFrom {
+		checked = append(checked, fileName)
+	}
+
+	if err := s.checkDeletions(syncState, checked); err != nil {
+		return summary, err
+	}
+
+	if err := s.runWorkers(ctx, updates, &summary, func(fileName string, summary *RunSummary) error {
+		mv, ok := moves[fileName]
+		if !ok {
+			return s.decideAction(syncState, fileName, summary)
+		}
+
+		moved, err := s.executeMove(syncState, mv, summary)
+		if err == nil && !moved {
+			fallbackLock.Lock()
+			fallbackDeletes = append(fallbackDeletes, mv.from)
+			fallbackLock.Unlock()
+		}
+		return err
+	}); err != nil {
+		return summary, err
+	}
+
+	if err := s.runWorkers(ctx, append(deletes, fallbackDeletes...), &summary, func(fileName string, summary *RunSummary) error {
+		return s.decideAction(syncState, fileName, summary)
+	}); err != nil {
+		return summary, err
+	}
+
+	s.pruneMoveSources(moves)
+
+	return summary, nil
+}
+
+func (s *Sync) runWorkers(ctx context.Context, fileNames []string, summary *RunSummary, execute func(string, *RunSummary) error) error {
+	var (
+		errC  = make(chan error, 1)
+		names = make(chan string)
+		lock  sync.Mutex
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < s.conf.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Every worker counts on its own, summaries are merged when done
+			var workerSummary RunSummary
+			defer func() {
+				lock.Lock()
+				summary.add(workerSummary)
+				lock.Unlock()
+			}()
+
+			for fileName := range names {
+				if err := execute(fileName, &workerSummary); err != nil {
+					select {
+					case errC <- errors.Wrap(err, "Could not execute sync"):
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, fileName := range fileNames {
+		select {
+		case <-ctx.Done():
+			break feed
+		case err := <-errC:
+			// Put the error back for the final check below
+			errC <- err
+			break feed
+		case names <- fileName:
+		}
+	}
+	close(names)
+	wg.Wait()
+
+	select {
+	case err := <-errC:
+		return err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "Sync aborted")
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/cloudbox/providers"
+)
+
+// operationRecorder records the order of puts and deletes on the
+// wrapped provider, puts are slowed down to let deletes overtake them
+// if both were run in parallel
+type operationRecorder struct {
+	providers.CloudProvider
+
+	lock       sync.Mutex
+	operations []string
+}
+
+func (o *operationRecorder) record(operation string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.operations = append(o.operations, operation)
+}
+
+func (o *operationRecorder) DeleteFile(relativeName string) error {
+	o.record("delete")
+	return o.CloudProvider.DeleteFile(relativeName)
+}
+
+func (o *operationRecorder) PutFile(f providers.File) (providers.File, error) {
+	time.Sleep(10 * time.Millisecond)
+	o.record("put")
+	return o.CloudProvider.PutFile(f)
+}
+
+func TestDeletesRunAfterAllUpdates(t *testing.T) {
+	s := newTestSync(t, Config{Concurrency: 8})
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		putTestFile(t, s.local, fmt.Sprintf("delete%d.txt", i), fmt.Sprintf("deleted content %d", i), base)
+	}
+	runTestSync(t, s)
+
+	recorder := &operationRecorder{CloudProvider: s.remote}
+	s.remote = recorder
+
+	for i := 0; i < 4; i++ {
+		if err := s.local.DeleteFile(fmt.Sprintf("delete%d.txt", i)); err != nil {
+			t.Fatalf("Unable to delete file: %s", err)
+		}
+		// Differing content to not be detected as moves
+		putTestFile(t, s.local, fmt.Sprintf("new%d.txt", i), fmt.Sprintf("new content %d", i), base)
+	}
+	runTestSync(t, s)
+
+	if ops := strings.Join(recorder.operations, ","); ops != "put,put,put,put,delete,delete,delete,delete" {
+		t.Errorf("Expected all puts before the deletes, got %s", ops)
+	}
+}
+
+func TestRunWorkersStopsOnError(t *testing.T) {
+	s := newTestSync(t, Config{Concurrency: 2})
+
+	var fileNames []string
+	for i := 0; i < 100; i++ {
+		fileNames = append(fileNames, fmt.Sprintf("file%d.txt", i))
+	}
+
+	var (
+		executed int
+		lock     sync.Mutex
+		errTest  = errors.New("test error")
+	)
+
+	err := s.runWorkers(context.Background(), fileNames, &RunSummary{}, func(fileName string, summary *RunSummary) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		executed++
+		if fileName == "file5.txt" {
+			return errTest
+		}
+		return nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), errTest.Error()) {
+		t.Fatalf("Expected the worker error to be returned, got %v", err)
+	}
+
+	if executed == len(fileNames) {
+		t.Error("Expected the run to stop after the error")
+	}
+}
+
+func TestRunWorkersStopsOnCancel(t *testing.T) {
+	s := newTestSync(t, Config{Concurrency: 2})
+
+	var fileNames []string
+	for i := 0; i < 100; i++ {
+		fileNames = append(fileNames, fmt.Sprintf("file%d.txt", i))
+	}
+
+	var (
+		executed    int
+		lock        sync.Mutex
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+
+	err := s.runWorkers(ctx, fileNames, &RunSummary{}, func(fileName string, summary *RunSummary) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if executed++; executed == 5 {
+			cancel()
+		}
+		return nil
+	})
+
+	if err == nil || ctx.Err() == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatalf("Expected the run to be aborted, got %v", err)
+	}
+
+	if executed == len(fileNames) {
+		t.Error("Expected the run to stop after cancellation")
+	}
+}